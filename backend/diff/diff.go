@@ -0,0 +1,109 @@
+// Package diff computes line-based differences between two texts, used to
+// compare revisions of notes.
+package diff
+
+import "strings"
+
+// maxCells bounds the size of the comparison table. Texts whose changed
+// region is larger are reported as a whole replacement.
+const maxCells = 4_000_000
+
+// Operations of a line in a diff
+const (
+	Equal  = "equal"
+	Insert = "insert"
+	Delete = "delete"
+)
+
+// Line is one line of a diff
+type Line struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines returns the lines of a and b as a sequence of equal, deleted and
+// inserted lines that turns a into b, using a longest common subsequence
+func Lines(a, b string) []Line {
+	linesA, linesB := split(a), split(b)
+
+	// Common prefix and suffix need no comparison table
+	prefix := 0
+	for prefix < len(linesA) && prefix < len(linesB) && linesA[prefix] == linesB[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(linesA)-prefix && suffix < len(linesB)-prefix &&
+		linesA[len(linesA)-1-suffix] == linesB[len(linesB)-1-suffix] {
+		suffix++
+	}
+
+	result := make([]Line, 0, len(linesA)+len(linesB))
+	for _, line := range linesA[:prefix] {
+		result = append(result, Line{Equal, line})
+	}
+	result = append(result, middle(linesA[prefix:len(linesA)-suffix], linesB[prefix:len(linesB)-suffix])...)
+	for _, line := range linesA[len(linesA)-suffix:] {
+		result = append(result, Line{Equal, line})
+	}
+	return result
+}
+
+// middle diffs the changed region of two texts
+func middle(a, b []string) []Line {
+	var result []Line
+	if len(a)*len(b) > maxCells {
+		for _, line := range a {
+			result = append(result, Line{Delete, line})
+		}
+		for _, line := range b {
+			result = append(result, Line{Insert, line})
+		}
+		return result
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			result = append(result, Line{Equal, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, Line{Delete, a[i]})
+			i++
+		default:
+			result = append(result, Line{Insert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		result = append(result, Line{Delete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		result = append(result, Line{Insert, b[j]})
+	}
+	return result
+}
+
+// split returns the lines of a text; an empty text has no lines
+func split(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
@@ -0,0 +1,240 @@
+// Package extract pulls plain text out of uploaded study materials so
+// they can be searched by their content.
+package extract
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// MaxTextBytes is the maximum length of the text kept for a material
+const MaxTextBytes = 1 << 20
+
+// maxPartBytes limits how much of each XML part of an Office document is
+// decompressed, protecting against zip bombs
+const maxPartBytes = 64 << 20
+
+// ErrUnsupported is returned for file types without an extractor
+var ErrUnsupported = errors.New("tipo de arquivo não suportado")
+
+// extractors maps lower-case file extensions to their extractor
+var extractors = map[string]func(path string) (string, error){
+	".pdf":  pdfText,
+	".docx": docxText,
+	".pptx": pptxText,
+	".txt":  plainText,
+}
+
+// Supported reports whether text can be extracted from a file with this name
+func Supported(name string) bool {
+	_, ok := extractors[strings.ToLower(filepath.Ext(name))]
+	return ok
+}
+
+// Text extracts the plain text of the file at path. name is the original
+// file name and selects the extractor by its extension. The result is
+// truncated to MaxTextBytes.
+func Text(path, name string) (string, error) {
+	extractor, ok := extractors[strings.ToLower(filepath.Ext(name))]
+	if !ok {
+		return "", ErrUnsupported
+	}
+	text, err := extractor(path)
+	if err != nil {
+		return "", err
+	}
+	return truncate(strings.TrimSpace(text), MaxTextBytes), nil
+}
+
+// plainText reads a text file as UTF-8, falling back to Latin-1 for files
+// saved by older editors
+func plainText(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// Read a little past the cap so a multi-byte rune at the end is kept whole
+	content, err := io.ReadAll(io.LimitReader(f, MaxTextBytes+utf8.UTFMax))
+	if err != nil {
+		return "", err
+	}
+	content = []byte(strings.TrimPrefix(string(content), "\uFEFF"))
+	if utf8.Valid(content) {
+		return string(content), nil
+	}
+	if len(content) > MaxTextBytes && utf8.Valid(content[:lastRuneStart(content)]) {
+		return string(content[:lastRuneStart(content)]), nil
+	}
+	return charmap.ISO8859_1.NewDecoder().String(string(content))
+}
+
+// lastRuneStart returns the index of the last rune start in b, so that a
+// rune cut by the read limit can be dropped before validation
+func lastRuneStart(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			return i
+		}
+	}
+	return len(b)
+}
+
+// pdfText extracts the text of every page of a PDF, one page per paragraph
+func pdfText(path string) (text string, err error) {
+	// The PDF parser panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = "", fmt.Errorf("PDF inválido: %v", r)
+		}
+	}()
+
+	f, reader, err := pdf.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var b strings.Builder
+	for i := 1; i <= reader.NumPage() && b.Len() < MaxTextBytes; i++ {
+		// Font names are page resources, so each page uses its own fonts
+		content, err := reader.Page(i).GetPlainText(nil)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(strings.TrimSpace(content))
+		b.WriteString("\n\n")
+	}
+	return b.String(), nil
+}
+
+// docxText extracts the paragraphs of a Word document
+func docxText(path string) (string, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer archive.Close()
+
+	for _, file := range archive.File {
+		if file.Name == "word/document.xml" {
+			var b strings.Builder
+			if err := xmlText(&b, file); err != nil {
+				return "", err
+			}
+			return b.String(), nil
+		}
+	}
+	return "", errors.New("documento Word inválido")
+}
+
+// pptxText extracts the text of the slides of a PowerPoint presentation,
+// in slide order
+func pptxText(path string) (string, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer archive.Close()
+
+	type slide struct {
+		number int
+		file   *zip.File
+	}
+	var slides []slide
+	for _, file := range archive.File {
+		name, ok := strings.CutPrefix(file.Name, "ppt/slides/slide")
+		if !ok {
+			continue
+		}
+		number, err := strconv.Atoi(strings.TrimSuffix(name, ".xml"))
+		if err == nil && strings.HasSuffix(name, ".xml") {
+			slides = append(slides, slide{number, file})
+		}
+	}
+	if len(slides) == 0 {
+		return "", errors.New("apresentação PowerPoint inválida")
+	}
+	sort.Slice(slides, func(i, j int) bool { return slides[i].number < slides[j].number })
+
+	var b strings.Builder
+	for _, s := range slides {
+		if b.Len() >= MaxTextBytes {
+			break
+		}
+		if err := xmlText(&b, s.file); err != nil {
+			return "", err
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+// xmlText writes the text runs of an Office Open XML part, breaking lines
+// at paragraphs
+func xmlText(b *strings.Builder, file *zip.File) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	decoder := xml.NewDecoder(io.LimitReader(rc, maxPartBytes))
+	inText := false
+	for b.Len() < MaxTextBytes {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				b.WriteString("\t")
+			case "br":
+				b.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				b.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+	return nil
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,206 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"studybuddy/models"
+	"studybuddy/storage"
+	"time"
+)
+
+// Pipeline defaults
+const (
+	defaultWorkers = 1
+	queueSize      = 256
+)
+
+// errNodeRemoved skips saving the text of a material deleted while its
+// text was being extracted
+var errNodeRemoved = errors.New("material removido")
+
+// job identifies an uploaded material waiting for extraction
+type job struct {
+	userID int64
+	nodeID string
+}
+
+// Pipeline extracts the text of uploaded materials in the background and
+// stores it with the user's material texts
+type Pipeline struct {
+	Workers int
+	jobs    chan job
+}
+
+// NewPipelineFromEnv returns a pipeline. EXTRACT_WORKERS sets the number of
+// concurrent extractions (default 1).
+func NewPipelineFromEnv() *Pipeline {
+	pipeline := &Pipeline{Workers: defaultWorkers, jobs: make(chan job, queueSize)}
+	if value := os.Getenv("EXTRACT_WORKERS"); value != "" {
+		if workers, err := strconv.Atoi(value); err == nil && workers > 0 {
+			pipeline.Workers = workers
+		} else {
+			log.Printf("WARNING: Invalid EXTRACT_WORKERS %q, using %d", value, defaultWorkers)
+		}
+	}
+	return pipeline
+}
+
+// Enqueue marks a material as pending and queues its extraction. When the
+// queue is full the material stays pending and is picked up by the backfill
+// on the next start.
+func (p *Pipeline) Enqueue(userID int64, nodeID string) error {
+	if err := markPending(userID, nodeID); err != nil {
+		return err
+	}
+	select {
+	case p.jobs <- job{userID, nodeID}:
+	default:
+		log.Printf("WARNING: Extraction queue full, material %s of user %d stays pending", nodeID, userID)
+	}
+	return nil
+}
+
+// Run starts the workers, queues the uploaded materials that have no text
+// yet and processes the queue until ctx is done
+func (p *Pipeline) Run(ctx context.Context) {
+	for i := 0; i < p.Workers; i++ {
+		go p.work(ctx)
+	}
+	p.backfill(ctx)
+	<-ctx.Done()
+}
+
+// work extracts queued materials until ctx is done
+func (p *Pipeline) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-p.jobs:
+			if err := process(j.userID, j.nodeID); err != nil && !errors.Is(err, errNodeRemoved) {
+				log.Printf("ERROR: Could not store text of material %s of user %d: %v", j.nodeID, j.userID, err)
+			}
+		}
+	}
+}
+
+// backfill queues every uploaded material without text or still pending,
+// e.g. files uploaded before extraction existed or while the server stopped
+func (p *Pipeline) backfill(ctx context.Context) {
+	for _, user := range storage.ListUsers() {
+		tree, err := storage.LoadMaterials(user.ID)
+		if err != nil {
+			log.Printf("ERROR: Could not load materials of user %d: %v", user.ID, err)
+			continue
+		}
+		texts, err := storage.LoadMaterialTexts(user.ID)
+		if err != nil {
+			log.Printf("ERROR: Could not load material texts of user %d: %v", user.ID, err)
+			continue
+		}
+
+		for _, node := range fileNodes(tree.Root) {
+			if text, ok := texts.Texts[node.ID]; ok && text.Status != models.TextPending {
+				continue
+			}
+			if err := markPending(user.ID, node.ID); err != nil {
+				log.Printf("ERROR: Could not queue material %s of user %d: %v", node.ID, user.ID, err)
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case p.jobs <- job{user.ID, node.ID}:
+			}
+		}
+	}
+}
+
+// process extracts the text of a material and stores the result
+func process(userID int64, nodeID string) error {
+	tree, err := storage.LoadMaterials(userID)
+	if err != nil {
+		return err
+	}
+	node := storage.FindNodeByID(tree.Root, nodeID)
+	if node == nil {
+		// The text of a trashed material is kept for its restore, which
+		// queues it again when it is still pending
+		trashed, err := storage.TrashedNode(userID, nodeID)
+		if err != nil || trashed != nil {
+			return err
+		}
+	}
+	if node == nil || !node.IsFile {
+		return forget(userID, nodeID)
+	}
+
+	result := models.MaterialText{Status: models.TextDone}
+	path := filepath.Clean(node.FilePath)
+	switch {
+	case !strings.HasPrefix(path, storage.UserUploadsDir(userID)+string(filepath.Separator)):
+		result = models.MaterialText{Status: models.TextFailed, Error: "caminho de arquivo inválido"}
+	case !Supported(node.FileName):
+		result = models.MaterialText{Status: models.TextUnsupported}
+	default:
+		text, err := Text(path, node.FileName)
+		if err != nil {
+			log.Printf("WARNING: Could not extract text of material %s of user %d: %v", nodeID, userID, err)
+			result = models.MaterialText{Status: models.TextFailed, Error: "não foi possível extrair o texto do arquivo"}
+		} else {
+			result.Text = text
+		}
+	}
+	result.ExtractedAt = time.Now().Format(time.RFC3339)
+
+	_, err = storage.UpdateMaterialTexts(userID, func(data *models.MaterialTextsData) error {
+		// The entry is removed together with the material
+		if _, ok := data.Texts[nodeID]; !ok {
+			return errNodeRemoved
+		}
+		data.Texts[nodeID] = result
+		return nil
+	})
+	return err
+}
+
+// markPending records that a material waits for extraction
+func markPending(userID int64, nodeID string) error {
+	_, err := storage.UpdateMaterialTexts(userID, func(data *models.MaterialTextsData) error {
+		data.Texts[nodeID] = models.MaterialText{Status: models.TextPending}
+		return nil
+	})
+	return err
+}
+
+// forget removes the text of a material that no longer exists
+func forget(userID int64, nodeID string) error {
+	_, err := storage.UpdateMaterialTexts(userID, func(data *models.MaterialTextsData) error {
+		if _, ok := data.Texts[nodeID]; !ok {
+			return errNodeRemoved
+		}
+		delete(data.Texts, nodeID)
+		return nil
+	})
+	return err
+}
+
+// fileNodes lists the uploaded files below node
+func fileNodes(node *models.MaterialNode) []*models.MaterialNode {
+	if node == nil {
+		return nil
+	}
+	var nodes []*models.MaterialNode
+	if node.IsFile {
+		nodes = append(nodes, node)
+	}
+	for _, child := range node.Children {
+		nodes = append(nodes, fileNodes(child)...)
+	}
+	return nodes
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// toProfile converts a user into its public profile, hiding the password hash
+func toProfile(user models.User) models.UserProfile {
+	return models.UserProfile{
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+// currentUser loads the authenticated user, writing an error response if it is missing
+func currentUser(c *gin.Context) (models.User, bool) {
+	user, exists := storage.GetUserByID(middleware.GetUserID(c))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// HandleGetAccount returns the authenticated user's profile
+func HandleGetAccount(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toProfile(user))
+}
+
+// HandleUpdateAccount updates the authenticated user's profile
+func HandleUpdateAccount(c *gin.Context) {
+	var req models.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	user, err := storage.UpdateUserName(middleware.GetUserID(c), strings.TrimSpace(req.Name))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar perfil"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toProfile(user))
+}
+
+// HandleChangePassword changes the authenticated user's password after
+// verifying the current one
+func HandleChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !storage.CheckPasswordHash(req.CurrentPassword, user.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Senha atual incorreta"})
+		return
+	}
+
+	if len(req.NewPassword) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A senha deve ter pelo menos %d caracteres", minPasswordLength)})
+		return
+	}
+
+	if _, err := storage.UpdatePassword(user.ID, req.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao alterar senha"})
+		return
+	}
+	// A reset link requested before the change must not override the new password
+	if err := storage.DeletePasswordResets(user.ID); err != nil {
+		log.Printf("ERROR: Could not remove password resets of user %d: %v", user.ID, err)
+	}
+
+	// Log out every other device that knew the old password
+	if err := storage.RevokeAllSessions(user.ID, middleware.GetSessionID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao encerrar outras sessões"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Senha alterada com sucesso"})
+}
+
+// HandleChangeEmail changes the authenticated user's email after verifying
+// the password
+func HandleChangeEmail(c *gin.Context) {
+	var req models.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	newEmail := strings.TrimSpace(req.Email)
+	if !strings.Contains(newEmail, "@") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email inválido"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !storage.CheckPasswordHash(req.Password, user.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Senha incorreta"})
+		return
+	}
+
+	updated, err := storage.ChangeUserEmail(user.ID, newEmail)
+	if err != nil {
+		if errors.Is(err, storage.ErrEmailTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email já cadastrado"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao alterar email"})
+		return
+	}
+	// Reset links sent to the old address must stop working
+	if updated.Email != user.Email {
+		if err := storage.DeletePasswordResets(user.ID); err != nil {
+			log.Printf("ERROR: Could not remove password resets of user %d: %v", user.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, toProfile(updated))
+}
+
+// HandleDeleteAccount permanently deletes the authenticated user's account
+// together with all of their data and uploaded files
+func HandleDeleteAccount(c *gin.Context) {
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !storage.CheckPasswordHash(req.Password, user.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Senha incorreta"})
+		return
+	}
+
+	if err := storage.DeleteUserData(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir dados da conta"})
+		return
+	}
+
+	if err := storage.DeleteUser(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir conta"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "conta removida"})
+}
+
+// HandleListSessions returns the authenticated user's active sessions
+func HandleListSessions(c *gin.Context) {
+	sessions, err := storage.ListSessions(middleware.GetUserID(c), middleware.GetSessionID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar sessões"})
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// HandleRevokeSession logs out one of the authenticated user's sessions
+func HandleRevokeSession(c *gin.Context) {
+	if err := storage.RevokeSession(middleware.GetUserID(c), c.Param("id")); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sessão não encontrada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao encerrar sessão"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "sessão encerrada"})
+}
+
+// HandleRevokeAllSessions logs the authenticated user out of all devices,
+// including the current one
+func HandleRevokeAllSessions(c *gin.Context) {
+	if err := storage.RevokeAllSessions(middleware.GetUserID(c), ""); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao encerrar sessões"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "todas as sessões foram encerradas"})
+}
@@ -1,13 +1,39 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"studybuddy/mail"
 	"studybuddy/models"
 	"studybuddy/storage"
 
 	"github.com/gin-gonic/gin"
 )
 
+// minPasswordLength is the minimum length accepted for new passwords
+const minPasswordLength = 6
+
+// mailSender delivers account emails such as password reset instructions
+var mailSender mail.Sender = mail.LogSender{}
+
+// SetMailSender configures how account emails are delivered
+func SetMailSender(sender mail.Sender) {
+	mailSender = sender
+}
+
+// AppBaseURL returns the public URL used in links sent by email and notifications
+func AppBaseURL() string {
+	if base := os.Getenv("APP_BASE_URL"); base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+	return "http://localhost:8080"
+}
+
 // HandleLogin handles user login
 func HandleLogin(c *gin.Context) {
 	var req models.LoginRequest
@@ -22,18 +48,81 @@ func HandleLogin(c *gin.Context) {
 		return
 	}
 
-	token, err := storage.GenerateJWT(user, req.RememberMe)
+	sessionID, refreshToken, err := storage.CreateSession(user.ID, req.RememberMe, c.Request.UserAgent())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar sessão"})
+		return
+	}
+
+	token, err := storage.GenerateJWT(user, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(storage.AccessTokenTTL.Seconds()),
+		User:         user,
+	})
+}
+
+// HandleRefresh exchanges a refresh token for a new access token and a
+// new refresh token. The old refresh token stops working.
+func HandleRefresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida"})
+		return
+	}
+
+	userID, sessionID, refreshToken, err := storage.RefreshSession(req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao renovar sessão"})
+		return
+	}
+
+	user, exists := storage.GetUserByID(userID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não encontrado"})
+		return
+	}
+
+	token, err := storage.GenerateJWT(user, sessionID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar token"})
 		return
 	}
 
 	c.JSON(http.StatusOK, models.AuthResponse{
-		Token: token,
-		User:  user,
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(storage.AccessTokenTTL.Seconds()),
+		User:         user,
 	})
 }
 
+// HandleLogout ends the session a refresh token belongs to
+func HandleLogout(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida"})
+		return
+	}
+
+	if err := storage.RevokeSessionByRefreshToken(req.RefreshToken); err != nil && !errors.Is(err, storage.ErrInvalidRefreshToken) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao encerrar sessão"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "sessão encerrada"})
+}
+
 // HandleRegister handles user registration
 func HandleRegister(c *gin.Context) {
 	var newUser struct {
@@ -60,3 +149,62 @@ func HandleRegister(c *gin.Context) {
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Usuário criado com sucesso"})
 }
+
+// HandleForgotPassword issues a password reset token and emails it to the user.
+// The response is the same whether or not the email is registered.
+func HandleForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida"})
+		return
+	}
+
+	user, exists := storage.GetUser(req.Email)
+	if exists {
+		token, err := storage.CreatePasswordResetToken(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar token de redefinição"})
+			return
+		}
+
+		link := fmt.Sprintf("%s/reset-password?token=%s", AppBaseURL(), url.QueryEscape(token))
+		msg := mail.Message{
+			To:      user.Email,
+			Subject: "StudyBuddy - Redefinição de senha",
+			Body: fmt.Sprintf("Olá %s,\n\nRecebemos uma solicitação para redefinir sua senha. "+
+				"Acesse o link abaixo para escolher uma nova senha (válido por %d minutos):\n\n%s\n\n"+
+				"Se você não solicitou a redefinição, ignore este email.",
+				user.Name, int(storage.PasswordResetTTL.Minutes()), link),
+		}
+		if err := mailSender.Send(msg); err != nil {
+			log.Printf("ERROR: Could not send password reset email to %s: %v", user.Email, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Se o email existir no sistema, enviaremos as instruções."})
+}
+
+// HandleResetPassword sets a new password using a reset token
+func HandleResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida"})
+		return
+	}
+
+	if len(req.Password) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A senha deve ter pelo menos %d caracteres", minPasswordLength)})
+		return
+	}
+
+	if _, err := storage.ResetPassword(req.Token, req.Password); err != nil {
+		if errors.Is(err, storage.ErrInvalidResetToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao redefinir senha"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Senha redefinida com sucesso"})
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"studybuddy/ical"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/recurrence"
+	"studybuddy/storage"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCalendarSize is the maximum size of an imported .ics file (1MB)
+const maxCalendarSize = 1 << 20
+
+// Reminder priorities mapped to iCalendar PRIORITY values
+var reminderPriorities = map[string]int{
+	"alta":  1,
+	"media": 5,
+	"baixa": 9,
+}
+
+// eventUID returns the iCalendar UID of an event. Imported events keep the
+// UID of their source calendar.
+func eventUID(event models.Event) string {
+	if event.UID != "" {
+		return event.UID
+	}
+	return fmt.Sprintf("event-%d@studybuddy", event.ID)
+}
+
+// parseItemTime parses the date and optional HH:MM time of an event or
+// reminder, reporting whether it is an all-day item
+func parseItemTime(date, clock string) (time.Time, bool, error) {
+	if clock == "" {
+		t, err := time.ParseInLocation("2006-01-02", date, time.Local)
+		return t, true, err
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04", date+" "+clock, time.Local)
+	return t, false, err
+}
+
+// calendarRecurrence converts a rule into an RRULE value and the skipped
+// occurrences, at the same time of day as start
+func calendarRecurrence(rule *models.Recurrence, start time.Time, allDay bool) (string, []time.Time) {
+	if rule == nil {
+		return "", nil
+	}
+	exdates := make([]time.Time, 0, len(rule.Exceptions))
+	for _, exception := range rule.Exceptions {
+		day, err := time.ParseInLocation("2006-01-02", exception, time.Local)
+		if err != nil {
+			continue
+		}
+		exdates = append(exdates, time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, time.Local))
+	}
+	return recurrence.RRule(rule, start, allDay), exdates
+}
+
+// importedRecurrence converts the RRULE and EXDATEs of an imported event
+// into a rule. Rules the application cannot repeat are dropped, leaving
+// only the first occurrence.
+func importedRecurrence(item ical.Event, date string) *models.Recurrence {
+	if item.RRule == "" {
+		return nil
+	}
+	rule, err := recurrence.ParseRRule(item.RRule)
+	if err != nil {
+		return nil
+	}
+	for _, exdate := range item.ExDates {
+		rule.Exceptions = append(rule.Exceptions, exdate.Format("2006-01-02"))
+	}
+	if recurrence.Validate(rule, date) != nil {
+		return nil
+	}
+	return rule
+}
+
+// calendarFeedURL returns the public URL of a calendar feed
+func calendarFeedURL(token string) string {
+	return fmt.Sprintf("%s/calendar/%s.ics", AppBaseURL(), token)
+}
+
+// HandleGetCalendarFeed reports whether the user's calendar feed is enabled.
+// The URL is only shown when the token is created.
+func HandleGetCalendarFeed(c *gin.Context) {
+	enabled, err := storage.CalendarFeedEnabled(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar link do calendário"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": enabled})
+}
+
+// HandleCreateCalendarFeed creates a new secret feed URL, invalidating the previous one
+func HandleCreateCalendarFeed(c *gin.Context) {
+	token, err := storage.CreateCalendarToken(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar link do calendário"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"enabled": true, "url": calendarFeedURL(token)})
+}
+
+// HandleDeleteCalendarFeed disables the user's calendar feed
+func HandleDeleteCalendarFeed(c *gin.Context) {
+	if err := storage.RevokeCalendarToken(middleware.GetUserID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao desativar link do calendário"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": false})
+}
+
+// HandleCalendarFeed serves a user's events and reminders as an iCalendar
+// feed. It is public: the secret token in the URL identifies the user.
+func HandleCalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+	userID, ok, err := storage.CalendarTokenUser(token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar calendário"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendário não encontrado"})
+		return
+	}
+	user, exists := storage.GetUserByID(userID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendário não encontrado"})
+		return
+	}
+
+	data, err := storage.LoadData(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler dados"})
+		return
+	}
+
+	events := make([]ical.Event, 0, len(data.Events))
+	for _, event := range data.Events {
+		start, allDay, err := parseItemTime(event.Date, event.Time)
+		if err != nil {
+			continue
+		}
+		rrule, exdates := calendarRecurrence(event.Recurrence, start, allDay)
+		events = append(events, ical.Event{
+			UID:         eventUID(event),
+			Summary:     event.Title,
+			Description: event.Description,
+			Start:       start,
+			AllDay:      allDay,
+			RRule:       rrule,
+			ExDates:     exdates,
+		})
+	}
+
+	todos := make([]ical.Todo, 0, len(data.Reminders))
+	for _, reminder := range data.Reminders {
+		due, allDay, err := parseItemTime(reminder.Date, reminder.Time)
+		if err != nil {
+			continue
+		}
+		rrule, exdates := calendarRecurrence(reminder.Recurrence, due, allDay)
+		todos = append(todos, ical.Todo{
+			UID:       fmt.Sprintf("reminder-%d@studybuddy", reminder.ID),
+			Summary:   reminder.Title,
+			Due:       due,
+			AllDay:    allDay,
+			Completed: reminder.Completed,
+			Priority:  reminderPriorities[reminder.Priority],
+			RRule:     rrule,
+			ExDates:   exdates,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := ical.Encode(&buf, "StudyBuddy - "+user.Name, events, todos, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar calendário"})
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", buf.Bytes())
+}
+
+// HandleImportCalendar imports the VEVENTs of an uploaded .ics file as
+// events. Events whose UID already exists are skipped.
+func HandleImportCalendar(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCalendarSize+1<<16)
+
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Erro ao receber arquivo"})
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, maxCalendarSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Erro ao receber arquivo"})
+		return
+	}
+	if len(content) > maxCalendarSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Arquivo muito grande. Limite: 1MB"})
+		return
+	}
+
+	parsed, err := ical.ParseEvents(bytes.NewReader(content))
+	if err != nil {
+		if errors.Is(err, ical.ErrInvalidCalendar) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Erro ao ler arquivo iCalendar"})
+		return
+	}
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	imported := make([]models.Event, 0)
+	skipped := 0
+	updated, err := storage.UpdateData(middleware.GetUserID(c), revision, func(data *models.AppData) error {
+		uids := make(map[string]bool, len(data.Events))
+		ids := make([]int64, 0, len(data.Events)+len(parsed))
+		for _, event := range data.Events {
+			uids[eventUID(event)] = true
+			ids = append(ids, event.ID)
+		}
+
+		for _, item := range parsed {
+			if item.UID != "" && uids[item.UID] {
+				skipped++
+				continue
+			}
+
+			event := models.Event{
+				ID:          storage.NextID(ids),
+				Title:       strings.TrimSpace(item.Summary),
+				Date:        item.Start.Format("2006-01-02"),
+				Description: item.Description,
+				UID:         item.UID,
+			}
+			if event.Title == "" {
+				event.Title = "Evento sem título"
+			}
+			if !item.AllDay {
+				event.Time = item.Start.Format("15:04")
+			}
+			event.Recurrence = importedRecurrence(item, event.Date)
+
+			ids = append(ids, event.ID)
+			if item.UID != "" {
+				uids[item.UID] = true
+			}
+			imported = append(imported, event)
+		}
+
+		data.Events = append(data.Events, imported...)
+		return nil
+	})
+	if err != nil {
+		respondDataError(c, err, "Dados não encontrados")
+		return
+	}
+	setRevisionHeader(c, updated.Revision)
+
+	c.JSON(http.StatusOK, gin.H{"imported": len(imported), "skipped": skipped, "events": imported})
+}
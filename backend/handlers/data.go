@@ -1,25 +1,35 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"studybuddy/middleware"
 	"studybuddy/models"
 	"studybuddy/storage"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// HandleGetData returns all application data
+// HandleGetData returns all application data of the authenticated user. The
+// ETag is only the revision for If-Match: the study log changes without a
+// new revision, so the response is never answered with 304.
 func HandleGetData(c *gin.Context) {
-	data, err := storage.LoadData()
+	data, err := storage.LoadData(middleware.GetUserID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler dados"})
 		return
 	}
+	setRevisionHeader(c, data.Revision)
+	c.Header("Cache-Control", "no-store")
 	c.JSON(http.StatusOK, data)
 }
 
-// HandleSaveData saves all application data
+// HandleSaveData saves all application data of the authenticated user.
+// When the If-Match header is sent, stale writes are rejected with 409.
 func HandleSaveData(c *gin.Context) {
 	var data models.AppData
 	if err := c.ShouldBindJSON(&data); err != nil {
@@ -27,28 +37,140 @@ func HandleSaveData(c *gin.Context) {
 		return
 	}
 
-	if err := storage.SaveData(data); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar dados"})
+	if err := validateAppData(data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "salvo"})
-}
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
 
-// HandleDeleteEvent deletes a specific event by ID
-func HandleDeleteEvent(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	saved, err := storage.SaveData(middleware.GetUserID(c), data, revision)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		respondDataError(c, err, "Dados não encontrados")
 		return
 	}
+	setRevisionHeader(c, saved.Revision)
+
+	c.JSON(http.StatusOK, gin.H{"status": "salvo", "revision": saved.Revision})
+}
+
+// validateAppData checks the repetition rules of the events and reminders
+// of a full save, as the granular endpoints do
+func validateAppData(data models.AppData) error {
+	for _, event := range data.Events {
+		if err := validateRecurrence(event.Recurrence, event.Date); err != nil {
+			return fmt.Errorf("Evento %q: %w", event.Title, err)
+		}
+	}
+	for _, reminder := range data.Reminders {
+		if err := validateRecurrence(reminder.Recurrence, reminder.Date); err != nil {
+			return fmt.Errorf("Lembrete %q: %w", reminder.Title, err)
+		}
+	}
+	return nil
+}
+
+// setRevisionHeader exposes a document revision as a strong ETag
+func setRevisionHeader(c *gin.Context, revision int64) {
+	c.Header("ETag", fmt.Sprintf("\"%d\"", revision))
+}
+
+// ifMatchRevision reads the revision from the If-Match header. A missing
+// header or "*" disables the check. Writes an error response if malformed.
+func ifMatchRevision(c *gin.Context) (int64, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return storage.AnyRevision, true
+	}
+
+	revision, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(header, "W/"), "\""), 10, 64)
+	if err != nil || revision < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cabeçalho If-Match inválido"})
+		return 0, false
+	}
+	return revision, true
+}
+
+// notModified answers 304 when If-None-Match matches the current revision
+func notModified(c *gin.Context, revision int64) bool {
+	if c.GetHeader("If-None-Match") == fmt.Sprintf("\"%d\"", revision) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// Reminder priorities accepted by the API
+var validPriorities = map[string]bool{
+	"baixa": true,
+	"media": true,
+	"alta":  true,
+}
+
+// isValidDate checks a date in the YYYY-MM-DD format used by the frontend
+func isValidDate(date string) bool {
+	_, err := time.Parse("2006-01-02", date)
+	return err == nil
+}
 
-	_, err = storage.DeleteEvent(id)
+// isValidTime checks a time in the HH:MM format used by the frontend.
+// An empty time is accepted for all-day items.
+func isValidTime(value string) bool {
+	if value == "" {
+		return true
+	}
+	_, err := time.Parse("15:04", value)
+	return err == nil
+}
+
+// parseItemID reads the numeric :id path parameter, writing an error response if invalid
+func parseItemID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao deletar evento"})
-		return
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return 0, false
 	}
+	return id, true
+}
+
+// validationError is returned by update callbacks when the request is invalid
+type validationError struct {
+	message string
+}
+
+func (e validationError) Error() string {
+	return e.message
+}
 
-	c.JSON(http.StatusOK, gin.H{"status": "evento removido"})
+// respondDataError writes the response for an error returned by storage.UpdateData.
+// Revision conflicts return 409 with the current data so the client can merge.
+func respondDataError(c *gin.Context, err error, notFoundMessage string) {
+	var invalid validationError
+	switch {
+	case errors.Is(err, storage.ErrRevisionConflict):
+		current, loadErr := storage.LoadData(middleware.GetUserID(c))
+		if loadErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler dados"})
+			return
+		}
+		setRevisionHeader(c, current.Revision)
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "current": current})
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalid.message})
+	case errors.Is(err, storage.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": notFoundMessage})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar dados"})
+	}
+}
+
+// requireTitle validates a mandatory title field
+func requireTitle(title *string) error {
+	if title == nil || strings.TrimSpace(*title) == "" {
+		return validationError{"O título é obrigatório"}
+	}
+	return nil
 }
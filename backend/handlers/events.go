@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// applyEventRequest validates and copies the fields present in req into event
+func applyEventRequest(event *models.Event, req models.EventRequest) error {
+	if req.Title != nil {
+		if err := requireTitle(req.Title); err != nil {
+			return err
+		}
+		event.Title = strings.TrimSpace(*req.Title)
+	}
+	if req.Date != nil {
+		if !isValidDate(*req.Date) {
+			return validationError{"Data inválida. Use o formato AAAA-MM-DD"}
+		}
+		event.Date = *req.Date
+	}
+	if req.Time != nil {
+		if !isValidTime(*req.Time) {
+			return validationError{"Horário inválido. Use o formato HH:MM"}
+		}
+		event.Time = *req.Time
+	}
+	if req.Description != nil {
+		event.Description = *req.Description
+	}
+	if req.Recurrence != nil {
+		setRecurrence(&event.Recurrence, req.Recurrence)
+	}
+	return validateRecurrence(event.Recurrence, event.Date)
+}
+
+// HandleListEvents returns the authenticated user's events
+func HandleListEvents(c *gin.Context) {
+	data, err := storage.LoadData(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler dados"})
+		return
+	}
+	setRevisionHeader(c, data.Revision)
+	if data.Events == nil {
+		data.Events = []models.Event{}
+	}
+	c.JSON(http.StatusOK, data.Events)
+}
+
+// HandleGetEvent returns a single event
+func HandleGetEvent(c *gin.Context) {
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+
+	data, err := storage.LoadData(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler dados"})
+		return
+	}
+	setRevisionHeader(c, data.Revision)
+
+	for _, event := range data.Events {
+		if event.ID == id {
+			c.JSON(http.StatusOK, event)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Evento não encontrado"})
+}
+
+// HandleCreateEvent creates an event with a server generated ID
+func HandleCreateEvent(c *gin.Context) {
+	var req models.EventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	var event models.Event
+	updated, err := storage.UpdateData(middleware.GetUserID(c), revision, func(data *models.AppData) error {
+		if err := requireTitle(req.Title); err != nil {
+			return err
+		}
+		if req.Date == nil {
+			return validationError{"A data é obrigatória"}
+		}
+		if err := applyEventRequest(&event, req); err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(data.Events))
+		for _, existing := range data.Events {
+			ids = append(ids, existing.ID)
+		}
+		event.ID = storage.NextID(ids)
+
+		data.Events = append(data.Events, event)
+		return nil
+	})
+	if err != nil {
+		respondDataError(c, err, "Evento não encontrado")
+		return
+	}
+	setRevisionHeader(c, updated.Revision)
+
+	c.JSON(http.StatusCreated, event)
+}
+
+// HandlePatchEvent updates the fields sent for an event
+func HandlePatchEvent(c *gin.Context) {
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+
+	var req models.EventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	var event models.Event
+	updated, err := storage.UpdateData(middleware.GetUserID(c), revision, func(data *models.AppData) error {
+		for i := range data.Events {
+			if data.Events[i].ID == id {
+				if err := applyEventRequest(&data.Events[i], req); err != nil {
+					return err
+				}
+				event = data.Events[i]
+				return nil
+			}
+		}
+		return storage.ErrNotFound
+	})
+	if err != nil {
+		respondDataError(c, err, "Evento não encontrado")
+		return
+	}
+	setRevisionHeader(c, updated.Revision)
+
+	c.JSON(http.StatusOK, event)
+}
+
+// HandleDeleteEvent moves a specific event to the trash
+func HandleDeleteEvent(c *gin.Context) {
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	updated, err := storage.DeleteEvent(middleware.GetUserID(c), revision, id)
+	if err != nil {
+		respondDataError(c, err, "Evento não encontrado")
+		return
+	}
+	setRevisionHeader(c, updated.Revision)
+
+	c.JSON(http.StatusOK, gin.H{"status": "evento movido para a lixeira"})
+}
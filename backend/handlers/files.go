@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/preview"
+	"studybuddy/storage"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// findFileMaterial returns an uploaded material of the owner userID, responding
+// with an error when it does not exist or is a link or folder
+func findFileMaterial(c *gin.Context, userID int64, materialID string) (models.MaterialNode, bool) {
+	node, ok, err := storage.FindMaterial(userID, materialID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+		return node, false
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material não encontrado"})
+		return node, false
+	}
+	if node.Type != "material" || !node.IsFile {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Este material não é um arquivo"})
+		return node, false
+	}
+	return node, true
+}
+
+// HandleCreateFileURL returns a short-lived signed URL to an uploaded
+// material, for <img>, <video>, <audio> and links, which cannot send the
+// Authorization header. With ?download=true the file is sent as an
+// attachment, and ?version=N selects a previous file of the material.
+// Video and audio URLs last long enough to play a lecture.
+func HandleCreateFileURL(c *gin.Context) {
+	access, ok := materialAccess(c, c.Param("id"), models.RoleViewer)
+	if !ok {
+		return
+	}
+	node, ok := findFileMaterial(c, access.OwnerID, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	version := 0
+	if value := c.Query("version"); value != "" {
+		var err error
+		if version, err = strconv.Atoi(value); err != nil || version < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Versão inválida"})
+			return
+		}
+	}
+
+	ttl := storage.FileTokenTTL
+	if kind := preview.Kind(node.ContentType); kind == preview.KindVideo || kind == preview.KindAudio {
+		ttl = storage.MediaTokenTTL
+	}
+	token, expiresAt, err := storage.GenerateFileToken(storage.FileToken{
+		UserID:     middleware.GetUserID(c),
+		SessionID:  middleware.GetSessionID(c),
+		MaterialID: node.ID,
+		Version:    version,
+		Download:   c.Query("download") == "true",
+	}, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar link do arquivo"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"url":       "/files/" + url.PathEscape(token),
+		"expiresAt": expiresAt.Format(time.RFC3339),
+	})
+}
+
+// HandleSignedFile serves the material a signed URL was issued for. The URL
+// stops working when it expires, when its session is revoked, when the
+// material is deleted or when the folder it is in is no longer shared.
+// URLs issued through a public link stop working when the link expires or
+// is revoked instead.
+func HandleSignedFile(c *gin.Context) {
+	token, err := storage.ParseFileToken(c.Param("token"))
+	if err == nil {
+		if token.LinkID != "" {
+			err = checkLinkFileToken(token)
+		} else if _, exists := storage.GetUserByID(token.UserID); !exists || !storage.IsSessionActive(token.UserID, token.SessionID) {
+			err = storage.ErrNotFound
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Link do arquivo expirado ou inválido"})
+		return
+	}
+
+	// Each file fetched through a public link counts against its limit
+	if token.LinkID != "" && startsDownload(c) {
+		if err := storage.RecordShareLinkDownload(token.LinkID); err != nil {
+			respondShareLinkError(c, err)
+			return
+		}
+	}
+
+	ownerID := token.UserID
+	if token.LinkID == "" {
+		access, err := storage.ResolveMaterialAccess(token.UserID, token.MaterialID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+			return
+		}
+		ownerID = access.OwnerID
+	}
+
+	disposition := "inline"
+	if token.Download {
+		disposition = "attachment"
+	}
+	serveMaterial(c, ownerID, token.MaterialID, token.Version, disposition)
+}
+
+// checkLinkFileToken checks that the public link a file URL was issued
+// through still exists and still contains the material
+func checkLinkFileToken(token storage.FileToken) error {
+	link, err := storage.ShareLinkByID(token.LinkID)
+	if err != nil {
+		return err
+	}
+	if link.OwnerID != token.UserID {
+		return storage.ErrShareLinkNotFound
+	}
+	if _, exists := storage.GetUserByID(link.OwnerID); !exists {
+		return storage.ErrShareLinkNotFound
+	}
+	within, err := storage.IsWithinNode(link.OwnerID, link.NodeID, token.MaterialID)
+	if err != nil {
+		return err
+	}
+	if !within {
+		return storage.ErrShareLinkNotFound
+	}
+	return nil
+}
@@ -0,0 +1,456 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/srs"
+	"studybuddy/storage"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultDeckName is used for notes without a subject
+const defaultDeckName = "Geral"
+
+// requestDate parses an optional YYYY-MM-DD date sent by the client,
+// defaulting to the server's current day
+func requestDate(value string) (time.Time, bool) {
+	if value == "" {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local), true
+	}
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	return date, err == nil
+}
+
+// findDeck returns the index of a deck by ID, or -1
+func findDeck(decks []models.Deck, id int64) int {
+	for i, deck := range decks {
+		if deck.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// findCard returns the index of a card by ID, or -1
+func findCard(cards []models.Flashcard, id int64) int {
+	for i, card := range cards {
+		if card.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// newCardID returns an unused card ID
+func newCardID(cards []models.Flashcard) int64 {
+	ids := make([]int64, 0, len(cards))
+	for _, card := range cards {
+		ids = append(ids, card.ID)
+	}
+	return storage.NextID(ids)
+}
+
+// newDeckID returns an unused deck ID
+func newDeckID(decks []models.Deck) int64 {
+	ids := make([]int64, 0, len(decks))
+	for _, deck := range decks {
+		ids = append(ids, deck.ID)
+	}
+	return storage.NextID(ids)
+}
+
+// HandleListDecks returns the authenticated user's decks
+func HandleListDecks(c *gin.Context) {
+	data, err := storage.LoadFlashcards(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar flashcards"})
+		return
+	}
+	if data.Decks == nil {
+		data.Decks = []models.Deck{}
+	}
+	c.JSON(http.StatusOK, data.Decks)
+}
+
+// HandleCreateDeck creates a deck
+func HandleCreateDeck(c *gin.Context) {
+	var req models.DeckRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	var deck models.Deck
+	_, err := storage.UpdateFlashcards(middleware.GetUserID(c), func(data *models.FlashcardsData) error {
+		deck = models.Deck{
+			ID:        newDeckID(data.Decks),
+			Name:      strings.TrimSpace(req.Name),
+			Subject:   req.Subject,
+			CreatedAt: time.Now().Format(time.RFC3339),
+		}
+		data.Decks = append(data.Decks, deck)
+		return nil
+	})
+	if err != nil {
+		respondDataError(c, err, "Baralho não encontrado")
+		return
+	}
+
+	c.JSON(http.StatusCreated, deck)
+}
+
+// HandleDeleteDeck deletes a deck together with its cards
+func HandleDeleteDeck(c *gin.Context) {
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+
+	_, err := storage.UpdateFlashcards(middleware.GetUserID(c), func(data *models.FlashcardsData) error {
+		index := findDeck(data.Decks, id)
+		if index == -1 {
+			return storage.ErrNotFound
+		}
+		data.Decks = append(data.Decks[:index], data.Decks[index+1:]...)
+
+		cards := make([]models.Flashcard, 0, len(data.Cards))
+		for _, card := range data.Cards {
+			if card.DeckID != id {
+				cards = append(cards, card)
+			}
+		}
+		data.Cards = cards
+		return nil
+	})
+	if err != nil {
+		respondDataError(c, err, "Baralho não encontrado")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "baralho removido"})
+}
+
+// HandleListCards returns the user's cards, optionally filtered by ?deckId=
+func HandleListCards(c *gin.Context) {
+	data, err := storage.LoadFlashcards(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar flashcards"})
+		return
+	}
+
+	deckID, _ := strconv.ParseInt(c.Query("deckId"), 10, 64)
+	cards := make([]models.Flashcard, 0, len(data.Cards))
+	for _, card := range data.Cards {
+		if deckID == 0 || card.DeckID == deckID {
+			cards = append(cards, card)
+		}
+	}
+	c.JSON(http.StatusOK, cards)
+}
+
+// HandleCreateCard creates a card in an existing deck, due today
+func HandleCreateCard(c *gin.Context) {
+	var req models.FlashcardRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.DeckID == nil || req.Front == nil || strings.TrimSpace(*req.Front) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Baralho e frente do cartão são obrigatórios"})
+		return
+	}
+
+	var card models.Flashcard
+	_, err := storage.UpdateFlashcards(middleware.GetUserID(c), func(data *models.FlashcardsData) error {
+		if findDeck(data.Decks, *req.DeckID) == -1 {
+			return validationError{"Baralho não encontrado"}
+		}
+
+		card = models.Flashcard{
+			ID:        newCardID(data.Cards),
+			DeckID:    *req.DeckID,
+			Front:     strings.TrimSpace(*req.Front),
+			CreatedAt: time.Now().Format(time.RFC3339),
+		}
+		if req.Back != nil {
+			card.Back = *req.Back
+		}
+		today, _ := requestDate("")
+		srs.NewCard(&card, today)
+
+		data.Cards = append(data.Cards, card)
+		return nil
+	})
+	if err != nil {
+		respondDataError(c, err, "Cartão não encontrado")
+		return
+	}
+
+	c.JSON(http.StatusCreated, card)
+}
+
+// HandleCreateCardFromNote derives a card from a note: the title becomes the
+// front and the content the back. Without a deckId the card goes to the deck
+// of the note's subject, which is created if needed.
+func HandleCreateCardFromNote(c *gin.Context) {
+	noteID, err := strconv.ParseInt(c.Param("noteId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req models.FromNoteRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+			return
+		}
+	}
+
+	userID := middleware.GetUserID(c)
+	appData, err := storage.LoadData(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler dados"})
+		return
+	}
+
+	var note *models.Note
+	for i := range appData.Notes {
+		if appData.Notes[i].ID == noteID {
+			note = &appData.Notes[i]
+			break
+		}
+	}
+	if note == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Anotação não encontrada"})
+		return
+	}
+
+	var card models.Flashcard
+	_, err = storage.UpdateFlashcards(userID, func(data *models.FlashcardsData) error {
+		deckID := req.DeckID
+		if deckID != 0 {
+			if findDeck(data.Decks, deckID) == -1 {
+				return validationError{"Baralho não encontrado"}
+			}
+		} else {
+			deckID = subjectDeck(data, note.Subject)
+		}
+
+		card = models.Flashcard{
+			ID:        newCardID(data.Cards),
+			DeckID:    deckID,
+			Front:     note.Title,
+			Back:      note.Content,
+			NoteID:    note.ID,
+			CreatedAt: time.Now().Format(time.RFC3339),
+		}
+		today, _ := requestDate("")
+		srs.NewCard(&card, today)
+
+		data.Cards = append(data.Cards, card)
+		return nil
+	})
+	if err != nil {
+		respondDataError(c, err, "Cartão não encontrado")
+		return
+	}
+
+	c.JSON(http.StatusCreated, card)
+}
+
+// subjectDeck returns the ID of the deck for a subject, creating it if needed
+func subjectDeck(data *models.FlashcardsData, subject string) int64 {
+	name := subject
+	if name == "" {
+		name = defaultDeckName
+	}
+	for _, deck := range data.Decks {
+		if deck.Subject == subject && deck.Name == name {
+			return deck.ID
+		}
+	}
+
+	deck := models.Deck{
+		ID:        newDeckID(data.Decks),
+		Name:      name,
+		Subject:   subject,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	data.Decks = append(data.Decks, deck)
+	return deck.ID
+}
+
+// HandlePatchCard updates the fields sent for a card
+func HandlePatchCard(c *gin.Context) {
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+
+	var req models.FlashcardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	var card models.Flashcard
+	_, err := storage.UpdateFlashcards(middleware.GetUserID(c), func(data *models.FlashcardsData) error {
+		index := findCard(data.Cards, id)
+		if index == -1 {
+			return storage.ErrNotFound
+		}
+
+		if req.DeckID != nil {
+			if findDeck(data.Decks, *req.DeckID) == -1 {
+				return validationError{"Baralho não encontrado"}
+			}
+			data.Cards[index].DeckID = *req.DeckID
+		}
+		if req.Front != nil {
+			if strings.TrimSpace(*req.Front) == "" {
+				return validationError{"A frente do cartão é obrigatória"}
+			}
+			data.Cards[index].Front = strings.TrimSpace(*req.Front)
+		}
+		if req.Back != nil {
+			data.Cards[index].Back = *req.Back
+		}
+		card = data.Cards[index]
+		return nil
+	})
+	if err != nil {
+		respondDataError(c, err, "Cartão não encontrado")
+		return
+	}
+
+	c.JSON(http.StatusOK, card)
+}
+
+// HandleDeleteCard deletes a card
+func HandleDeleteCard(c *gin.Context) {
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+
+	_, err := storage.UpdateFlashcards(middleware.GetUserID(c), func(data *models.FlashcardsData) error {
+		index := findCard(data.Cards, id)
+		if index == -1 {
+			return storage.ErrNotFound
+		}
+		data.Cards = append(data.Cards[:index], data.Cards[index+1:]...)
+		return nil
+	})
+	if err != nil {
+		respondDataError(c, err, "Cartão não encontrado")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "cartão removido"})
+}
+
+// HandleDueCards returns the cards due on ?date= (default today), optionally
+// filtered by ?deckId=, most overdue first
+func HandleDueCards(c *gin.Context) {
+	today, ok := requestDate(c.Query("date"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data inválida. Use o formato AAAA-MM-DD"})
+		return
+	}
+
+	data, err := storage.LoadFlashcards(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar flashcards"})
+		return
+	}
+
+	deckID, _ := strconv.ParseInt(c.Query("deckId"), 10, 64)
+	due := make([]models.Flashcard, 0)
+	for _, card := range data.Cards {
+		if (deckID == 0 || card.DeckID == deckID) && srs.IsDue(card, today) {
+			due = append(due, card)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].DueDate < due[j].DueDate
+	})
+
+	c.JSON(http.StatusOK, due)
+}
+
+// maxReviewSeconds caps the time one review adds to the study log, as the
+// duration is measured by the client
+const maxReviewSeconds = 5 * 60
+
+// HandleReviewCard records a review: the card is rescheduled with SM-2 and
+// the time spent, up to maxReviewSeconds, is added to the study log of the
+// server's current day. The client's date only schedules the card, and may
+// differ from the server's by one day for other time zones.
+func HandleReviewCard(c *gin.Context) {
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+
+	var req models.ReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+	if *req.Grade < 0 || *req.Grade > srs.MaxGrade {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A nota deve estar entre 0 e 5"})
+		return
+	}
+	if req.DurationSeconds < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Duração inválida"})
+		return
+	}
+	duration := min(req.DurationSeconds, maxReviewSeconds)
+
+	today, ok := requestDate(req.Date)
+	serverToday, _ := requestDate("")
+	if !ok || today.Before(serverToday.AddDate(0, 0, -1)) || today.After(serverToday.AddDate(0, 0, 1)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data inválida. Use a data atual no formato AAAA-MM-DD"})
+		return
+	}
+	day := serverToday.Format("2006-01-02")
+
+	userID := middleware.GetUserID(c)
+	var card models.Flashcard
+	minutes := 0
+	_, err := storage.UpdateFlashcards(userID, func(data *models.FlashcardsData) error {
+		index := findCard(data.Cards, id)
+		if index == -1 {
+			return storage.ErrNotFound
+		}
+
+		srs.Review(&data.Cards[index], *req.Grade, today)
+		card = data.Cards[index]
+
+		// Only whole minutes go to the study log; the remainder is kept for later reviews
+		if data.ReviewSeconds == nil {
+			data.ReviewSeconds = make(map[string]int)
+		}
+		before := data.ReviewSeconds[day] / 60
+		data.ReviewSeconds[day] += duration
+		minutes = data.ReviewSeconds[day]/60 - before
+		return nil
+	})
+	if err != nil {
+		respondDataError(c, err, "Cartão não encontrado")
+		return
+	}
+
+	if minutes > 0 {
+		if err := storage.AddStudyMinutes(userID, map[string]int{day: minutes}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar tempo de estudo"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"card": card, "studyMinutesAdded": minutes})
+}
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/stats"
+	"studybuddy/storage"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limits for the planned duration of a focus session, matching the timer UI
+const (
+	defaultFocusMinutes = 25
+	maxFocusMinutes     = 240
+)
+
+// focusGracePeriod is how long a session may keep running after its planned
+// duration before it is finished automatically. Time past the planned
+// duration is never counted.
+const focusGracePeriod = 15 * time.Minute
+
+// errNoOverdueFocus is returned by loadFocus to skip saving when no session
+// had to be finished
+var errNoOverdueFocus = errors.New("no overdue focus session")
+
+// focusStateError is returned when a session cannot change to the requested state
+type focusStateError struct {
+	message string
+}
+
+func (e focusStateError) Error() string {
+	return e.message
+}
+
+// activeFocusSession returns the index of the running or paused session, or -1
+func activeFocusSession(sessions []models.FocusSession) int {
+	for i, session := range sessions {
+		if session.Status != models.FocusFinished {
+			return i
+		}
+	}
+	return -1
+}
+
+// findFocusSession returns the index of a session by ID, or -1
+func findFocusSession(sessions []models.FocusSession, id int64) int {
+	for i, session := range sessions {
+		if session.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// closeFocusSegment ends the running segment of a session at now, or when
+// the planned duration ran out, and returns the focused seconds per day it
+// covered
+func closeFocusSegment(session *models.FocusSession, now time.Time) map[string]int {
+	last := &session.Segments[len(session.Segments)-1]
+	start, err := time.Parse(time.RFC3339, last.Start)
+	if err != nil {
+		last.End = now.Format(time.RFC3339)
+		return nil
+	}
+	end := stats.SegmentEnd(*session, start, now)
+	last.End = end.Format(time.RFC3339)
+
+	seconds := stats.SecondsByDay(start, end, time.Local)
+	for _, value := range seconds {
+		session.ElapsedSeconds += value
+	}
+	return seconds
+}
+
+// finishOverdueFocusSessions finishes the running sessions that passed their
+// planned duration by more than focusGracePeriod, as if they were finished
+// when the planned time ran out. It returns the focused seconds per day of
+// the segments it closed.
+func finishOverdueFocusSessions(data *models.FocusData, now time.Time) map[string]int {
+	seconds := make(map[string]int)
+	for i := range data.Sessions {
+		session := &data.Sessions[i]
+		if session.Status != models.FocusRunning || len(session.Segments) == 0 {
+			continue
+		}
+		start, err := time.Parse(time.RFC3339, session.Segments[len(session.Segments)-1].Start)
+		if err != nil || now.Sub(stats.SegmentEnd(*session, start, now)) < focusGracePeriod {
+			continue
+		}
+		for day, value := range closeFocusSegment(session, now) {
+			seconds[day] += value
+		}
+		session.Status = models.FocusFinished
+		session.FinishedAt = session.Segments[len(session.Segments)-1].End
+	}
+	return seconds
+}
+
+// loadFocus loads the user's focus sessions, first finishing the overdue
+// ones and adding their time to the study log
+func loadFocus(userID int64, now time.Time) (models.FocusData, error) {
+	var minutes map[string]int
+	data, err := storage.UpdateFocus(userID, func(data *models.FocusData) error {
+		seconds := finishOverdueFocusSessions(data, now)
+		if len(seconds) == 0 {
+			return errNoOverdueFocus
+		}
+		minutes = logFocusSeconds(data, seconds)
+		return nil
+	})
+	if errors.Is(err, errNoOverdueFocus) {
+		return storage.LoadFocus(userID)
+	}
+	if err != nil {
+		return data, err
+	}
+	return data, storage.AddStudyMinutes(userID, minutes)
+}
+
+// logFocusSeconds adds closed-segment time to the per-day counters and
+// returns the whole minutes that must be added to the study log
+func logFocusSeconds(data *models.FocusData, seconds map[string]int) map[string]int {
+	if data.LoggedSeconds == nil {
+		data.LoggedSeconds = make(map[string]int)
+	}
+	minutes := make(map[string]int)
+	for day, value := range seconds {
+		before := data.LoggedSeconds[day] / 60
+		data.LoggedSeconds[day] += value
+		if added := data.LoggedSeconds[day]/60 - before; added > 0 {
+			minutes[day] = added
+		}
+	}
+	return minutes
+}
+
+// liveFocusSession returns a copy of a session whose ElapsedSeconds includes
+// the running segment up to now, within the planned duration
+func liveFocusSession(session models.FocusSession, now time.Time) models.FocusSession {
+	if session.Status != models.FocusRunning || len(session.Segments) == 0 {
+		return session
+	}
+	start, err := time.Parse(time.RFC3339, session.Segments[len(session.Segments)-1].Start)
+	if end := stats.SegmentEnd(session, start, now); err == nil && end.After(start) {
+		session.ElapsedSeconds += int(end.Sub(start).Seconds())
+	}
+	return session
+}
+
+// respondFocusError writes the response for an error returned by storage.UpdateFocus
+func respondFocusError(c *gin.Context, err error) {
+	var state focusStateError
+	if errors.As(err, &state) {
+		c.JSON(http.StatusConflict, gin.H{"error": state.message})
+		return
+	}
+	respondDataError(c, err, "Sessão não encontrada")
+}
+
+// HandleListFocusSessions returns the user's focus sessions, optionally
+// limited to those started between ?from= and ?to= (YYYY-MM-DD, inclusive)
+func HandleListFocusSessions(c *gin.Context) {
+	from, to := c.Query("from"), c.Query("to")
+	if (from != "" && !isValidDate(from)) || (to != "" && !isValidDate(to)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data inválida. Use o formato AAAA-MM-DD"})
+		return
+	}
+
+	now := time.Now()
+	data, err := loadFocus(middleware.GetUserID(c), now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar sessões de foco"})
+		return
+	}
+
+	sessions := make([]models.FocusSession, 0, len(data.Sessions))
+	for _, session := range data.Sessions {
+		started, err := time.Parse(time.RFC3339, session.StartedAt)
+		if err != nil {
+			continue
+		}
+		day := started.Local().Format("2006-01-02")
+		if (from != "" && day < from) || (to != "" && day > to) {
+			continue
+		}
+		sessions = append(sessions, liveFocusSession(session, now))
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// HandleCurrentFocusSession returns the running or paused session so it can
+// be resumed on any device
+func HandleCurrentFocusSession(c *gin.Context) {
+	now := time.Now()
+	data, err := loadFocus(middleware.GetUserID(c), now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar sessões de foco"})
+		return
+	}
+
+	index := activeFocusSession(data.Sessions)
+	if index == -1 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Nenhuma sessão em andamento"})
+		return
+	}
+	c.JSON(http.StatusOK, liveFocusSession(data.Sessions[index], now))
+}
+
+// HandleStartFocusSession starts a focus session. Only one session can be
+// active at a time.
+func HandleStartFocusSession(c *gin.Context) {
+	var req models.FocusStartRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+			return
+		}
+	}
+	if req.PlannedMinutes == 0 {
+		req.PlannedMinutes = defaultFocusMinutes
+	}
+	if req.PlannedMinutes < 0 || req.PlannedMinutes > maxFocusMinutes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A duração deve estar entre 1 e 240 minutos"})
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	if _, err := loadFocus(userID, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar sessões de foco"})
+		return
+	}
+
+	now := time.Now()
+	var session models.FocusSession
+	var active *models.FocusSession
+	_, err := storage.UpdateFocus(userID, func(data *models.FocusData) error {
+		if index := activeFocusSession(data.Sessions); index != -1 {
+			current := liveFocusSession(data.Sessions[index], now)
+			active = &current
+			return focusStateError{"Já existe uma sessão em andamento"}
+		}
+
+		ids := make([]int64, 0, len(data.Sessions))
+		for _, existing := range data.Sessions {
+			ids = append(ids, existing.ID)
+		}
+		session = models.FocusSession{
+			ID:             storage.NextID(ids),
+			Subject:        strings.TrimSpace(req.Subject),
+			PlannedMinutes: req.PlannedMinutes,
+			Status:         models.FocusRunning,
+			Segments:       []models.FocusSegment{{Start: now.Format(time.RFC3339)}},
+			StartedAt:      now.Format(time.RFC3339),
+		}
+		data.Sessions = append(data.Sessions, session)
+		return nil
+	})
+	if active != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "current": active})
+		return
+	}
+	if err != nil {
+		respondFocusError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, session)
+}
+
+// HandlePauseFocusSession pauses a running session
+func HandlePauseFocusSession(c *gin.Context) {
+	changeFocusSession(c, func(session *models.FocusSession, now time.Time) (map[string]int, error) {
+		if session.Status != models.FocusRunning {
+			return nil, focusStateError{"A sessão não está em andamento"}
+		}
+		session.Status = models.FocusPaused
+		return closeFocusSegment(session, now), nil
+	})
+}
+
+// HandleResumeFocusSession resumes a paused session
+func HandleResumeFocusSession(c *gin.Context) {
+	changeFocusSession(c, func(session *models.FocusSession, now time.Time) (map[string]int, error) {
+		if session.Status != models.FocusPaused {
+			return nil, focusStateError{"A sessão não está pausada"}
+		}
+		session.Status = models.FocusRunning
+		session.Segments = append(session.Segments, models.FocusSegment{Start: now.Format(time.RFC3339)})
+		return nil, nil
+	})
+}
+
+// HandleFinishFocusSession finishes a running or paused session
+func HandleFinishFocusSession(c *gin.Context) {
+	changeFocusSession(c, func(session *models.FocusSession, now time.Time) (map[string]int, error) {
+		var seconds map[string]int
+		switch session.Status {
+		case models.FocusRunning:
+			seconds = closeFocusSegment(session, now)
+		case models.FocusPaused:
+		default:
+			return nil, focusStateError{"A sessão já foi finalizada"}
+		}
+		session.Status = models.FocusFinished
+		session.FinishedAt = now.Format(time.RFC3339)
+		return seconds, nil
+	})
+}
+
+// changeFocusSession applies a state change to the :id session using the
+// server clock and adds the focused time it closed to the study log
+func changeFocusSession(c *gin.Context, change func(session *models.FocusSession, now time.Time) (map[string]int, error)) {
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	now := time.Now()
+	var session models.FocusSession
+	var minutes map[string]int
+	_, err := storage.UpdateFocus(userID, func(data *models.FocusData) error {
+		index := findFocusSession(data.Sessions, id)
+		if index == -1 {
+			return storage.ErrNotFound
+		}
+
+		seconds, err := change(&data.Sessions[index], now)
+		if err != nil {
+			return err
+		}
+		minutes = logFocusSeconds(data, seconds)
+		session = data.Sessions[index]
+		return nil
+	})
+	if err != nil {
+		respondFocusError(c, err)
+		return
+	}
+
+	if err := storage.AddStudyMinutes(userID, minutes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar tempo de estudo"})
+		return
+	}
+
+	c.JSON(http.StatusOK, liveFocusSession(session, now))
+}
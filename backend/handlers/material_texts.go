@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"studybuddy/extract"
+	"studybuddy/models"
+	"studybuddy/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// textExtractor extracts the text of uploaded materials in the background
+var textExtractor *extract.Pipeline
+
+// SetTextExtractor configures the pipeline that extracts the text of uploaded materials
+func SetTextExtractor(pipeline *extract.Pipeline) {
+	textExtractor = pipeline
+}
+
+// queueTextExtraction queues the extraction of an uploaded material's text
+func queueTextExtraction(userID int64, nodeID string) error {
+	if textExtractor == nil {
+		return nil
+	}
+	return textExtractor.Enqueue(userID, nodeID)
+}
+
+// HandleGetMaterialText returns the extraction status and text of an uploaded material
+func HandleGetMaterialText(c *gin.Context) {
+	nodeID := c.Param("id")
+	access, ok := materialAccess(c, nodeID, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	tree, err := storage.LoadMaterials(access.OwnerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+		return
+	}
+	node := storage.FindNodeByID(tree.Root, nodeID)
+	if node == nil || !node.IsFile {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Arquivo não encontrado"})
+		return
+	}
+
+	texts, err := storage.LoadMaterialTexts(access.OwnerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar texto do material"})
+		return
+	}
+	text, ok := texts.Texts[nodeID]
+	if !ok {
+		text = models.MaterialText{Status: models.TextPending}
+	}
+	if text.Status == models.TextDone {
+		if text.Text, err = storage.LoadMaterialText(access.OwnerID, nodeID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar texto do material"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, text)
+}
+
+// HandleExtractMaterialText queues the text extraction of an uploaded
+// material again, e.g. after a failure
+func HandleExtractMaterialText(c *gin.Context) {
+	nodeID := c.Param("id")
+	access, ok := materialAccess(c, nodeID, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	tree, err := storage.LoadMaterials(access.OwnerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+		return
+	}
+	node := storage.FindNodeByID(tree.Root, nodeID)
+	if node == nil || !node.IsFile {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Arquivo não encontrado"})
+		return
+	}
+
+	if err := queueTextExtraction(access.OwnerID, nodeID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao agendar extração de texto"})
+		return
+	}
+	c.JSON(http.StatusAccepted, models.MaterialText{Status: models.TextPending})
+}
@@ -1,34 +1,48 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"studybuddy/middleware"
 	"studybuddy/models"
 	"studybuddy/storage"
-	"time"
 
+	"github.com/gabriel-vasile/mimetype"
 	"github.com/gin-gonic/gin"
 )
 
-// HandleGetMaterials returns the entire materials tree
+// HandleGetMaterials returns the authenticated user's materials tree
 func HandleGetMaterials(c *gin.Context) {
-	tree, err := storage.LoadMaterials()
+	userID := middleware.GetUserID(c)
+	tree, err := storage.LoadMaterials(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
 		return
 	}
+	setRevisionHeader(c, tree.Revision)
+	if notModified(c, tree.Revision) {
+		return
+	}
 	c.JSON(http.StatusOK, tree)
 }
 
-// HandleGetMaterialNode returns a specific node and its children
+// HandleGetMaterialNode returns a specific node and its children, from the
+// user's tree or from a folder shared with the user
 func HandleGetMaterialNode(c *gin.Context) {
 	nodeID := c.Param("id")
+	access, ok := materialAccess(c, nodeID, models.RoleViewer)
+	if !ok {
+		return
+	}
 
-	tree, err := storage.LoadMaterials()
+	tree, err := storage.LoadMaterials(access.OwnerID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
 		return
@@ -39,11 +53,45 @@ func HandleGetMaterialNode(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Nó não encontrado"})
 		return
 	}
+	if isShared(c, access) {
+		storage.StripShares(node)
+	}
 
+	setRevisionHeader(c, tree.Revision)
 	c.JSON(http.StatusOK, node)
 }
 
-// HandleCreateFolder creates a new folder
+// treeError marks an error returned by a tree operation as a client error
+func treeError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return validationError{err.Error()}
+}
+
+// respondMaterialsError writes the response for an error returned by
+// storage.UpdateMaterials. Revision conflicts return 409 with the current
+// tree so the client can merge.
+func respondMaterialsError(c *gin.Context, err error) {
+	var invalid validationError
+	switch {
+	case errors.Is(err, storage.ErrRevisionConflict):
+		current, loadErr := storage.LoadMaterials(middleware.GetUserID(c))
+		if loadErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+			return
+		}
+		setRevisionHeader(c, current.Revision)
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "current": current})
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalid.message})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar materiais"})
+	}
+}
+
+// HandleCreateFolder creates a new folder, in the user's tree or in a
+// folder shared with the user as editor
 func HandleCreateFolder(c *gin.Context) {
 	var req models.CreateFolderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -51,23 +99,34 @@ func HandleCreateFolder(c *gin.Context) {
 		return
 	}
 
-	tree, err := storage.LoadMaterials()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+	access, ok := materialAccess(c, req.ParentID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	revision, ok := ifMatchRevision(c)
+	if !ok {
 		return
 	}
 
-	folder, err := storage.AddFolder(tree, req.Name, req.ParentID)
+	var folder *models.MaterialNode
+	tree, err := storage.UpdateMaterials(access.OwnerID, revision, func(tree *models.MaterialsTree) error {
+		var err error
+		folder, err = storage.AddFolder(tree, req.Name, req.ParentID)
+		return treeError(err)
+	})
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondAccessMaterialsError(c, access, err)
 		return
 	}
 
+	setRevisionHeader(c, tree.Revision)
 	c.JSON(http.StatusCreated, folder)
 }
 
-// HandleCreateMaterial creates a new material
+// HandleCreateMaterial creates a new material, in the user's tree or in a
+// folder shared with the user as editor
 func HandleCreateMaterial(c *gin.Context) {
+	userID := middleware.GetUserID(c)
 	var req models.CreateMaterialRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
@@ -85,18 +144,49 @@ func HandleCreateMaterial(c *gin.Context) {
 		return
 	}
 
-	tree, err := storage.LoadMaterials()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+	access, ok := materialAccess(c, req.ParentID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	revision, ok := ifMatchRevision(c)
+	if !ok {
 		return
 	}
 
-	material, err := storage.AddMaterialWithFile(tree, req.Name, req.ParentID, req.MaterialType, req.URL, req.FilePath, req.FileName, req.FileSize, req.Description, req.IsFile)
+	fileName := req.FileName
+	if req.IsFile {
+		fileName = sanitizeFileName(fileName)
+	}
+	var material *models.MaterialNode
+	tree, err := storage.UpdateMaterials(access.OwnerID, revision, func(tree *models.MaterialsTree) error {
+		var err error
+		filePath, contentType := req.FilePath, ""
+		if req.IsFile {
+			// Checked under the materials lock so the upload cannot be
+			// collected before the material references it
+			if filePath, contentType, err = adoptUpload(userID, access, req.FilePath, fileName); err != nil {
+				return err
+			}
+		}
+		material, err = storage.AddMaterialWithFile(tree, req.Name, req.ParentID, req.MaterialType, req.URL, filePath, fileName, req.FileSize, req.Description, req.IsFile)
+		if err != nil {
+			return treeError(err)
+		}
+		material.ContentType = contentType
+		return nil
+	})
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondAccessMaterialsError(c, access, err)
 		return
 	}
 
+	if material.IsFile {
+		if err := queueTextExtraction(access.OwnerID, material.ID); err != nil {
+			log.Printf("ERROR: Could not queue text extraction of material %s: %v", material.ID, err)
+		}
+	}
+
+	setRevisionHeader(c, tree.Revision)
 	c.JSON(http.StatusCreated, material)
 }
 
@@ -110,41 +200,76 @@ func HandleUpdateNode(c *gin.Context) {
 		return
 	}
 
-	tree, err := storage.LoadMaterials()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+	access, ok := materialAccess(c, nodeID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	revision, ok := ifMatchRevision(c)
+	if !ok {
 		return
 	}
 
-	if err := storage.UpdateNode(tree, nodeID, req.Name, req.MaterialType, req.URL, req.Description); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	tree, err := storage.UpdateMaterials(access.OwnerID, revision, func(tree *models.MaterialsTree) error {
+		return treeError(storage.UpdateNode(tree, nodeID, req.Name, req.MaterialType, req.URL, req.Description))
+	})
+	if err != nil {
+		respondAccessMaterialsError(c, access, err)
 		return
 	}
 
 	// Return the updated node
 	node := storage.FindNodeByID(tree.Root, nodeID)
+	if isShared(c, access) {
+		storage.StripShares(node)
+	}
+	setRevisionHeader(c, tree.Revision)
 	c.JSON(http.StatusOK, node)
 }
 
-// HandleDeleteNode deletes a node and its children
+// HandleDeleteNode moves a node and its children to the trash. Items
+// deleted inside a shared folder go to the owner's trash.
 func HandleDeleteNode(c *gin.Context) {
 	nodeID := c.Param("id")
 
-	tree, err := storage.LoadMaterials()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+	access, ok := materialAccess(c, nodeID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	if access.Direct {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Apenas o dono pode excluir ou mover um item compartilhado"})
+		return
+	}
+	revision, ok := ifMatchRevision(c)
+	if !ok {
 		return
 	}
 
-	if err := storage.DeleteNode(tree, nodeID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	tree, err := storage.TrashMaterials(access.OwnerID, revision, func(tree *models.MaterialsTree) (models.TrashItem, error) {
+		node := storage.FindNodeByID(tree.Root, nodeID)
+		path := storage.FolderPath(tree.Root, nodeID)
+		if err := storage.DeleteNode(tree, nodeID); err != nil {
+			return models.TrashItem{}, treeError(err)
+		}
+		// Uploaded files, texts and playback positions are kept until the
+		// item is purged from the trash
+		return models.TrashItem{
+			Kind: models.TrashMaterial,
+			Name: node.Name,
+			Path: path,
+			Node: node,
+		}, nil
+	})
+	if err != nil {
+		respondAccessMaterialsError(c, access, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "removido"})
+	setRevisionHeader(c, tree.Revision)
+	c.JSON(http.StatusOK, gin.H{"status": "movido para a lixeira"})
 }
 
-// HandleMoveNode moves a node to a different parent
+// HandleMoveNode moves a node to a different parent. Inside shared
+// folders, nodes can only move within the same owner's tree.
 func HandleMoveNode(c *gin.Context) {
 	nodeID := c.Param("id")
 
@@ -154,30 +279,49 @@ func HandleMoveNode(c *gin.Context) {
 		return
 	}
 
-	tree, err := storage.LoadMaterials()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+	access, ok := materialAccess(c, nodeID, models.RoleEditor)
+	if !ok {
 		return
 	}
-
-	if err := storage.MoveNode(tree, nodeID, req.NewParentID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if access.Direct {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Apenas o dono pode excluir ou mover um item compartilhado"})
+		return
+	}
+	target, ok := materialAccess(c, req.NewParentID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	if target.OwnerID != access.OwnerID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Não é possível mover itens entre materiais de usuários diferentes"})
+		return
+	}
+	revision, ok := ifMatchRevision(c)
+	if !ok {
 		return
 	}
 
-	// Return the updated tree
-	updatedTree, err := storage.LoadMaterials()
+	tree, err := storage.UpdateMaterials(access.OwnerID, revision, func(tree *models.MaterialsTree) error {
+		return treeError(storage.MoveNode(tree, nodeID, req.NewParentID))
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais atualizados"})
+		respondAccessMaterialsError(c, access, err)
+		return
+	}
+
+	// Return the updated tree, or the moved node inside a shared folder
+	setRevisionHeader(c, tree.Revision)
+	if isShared(c, access) {
+		node := storage.FindNodeByID(tree.Root, nodeID)
+		storage.StripShares(node)
+		c.JSON(http.StatusOK, node)
 		return
 	}
-	c.JSON(http.StatusOK, updatedTree)
+	c.JSON(http.StatusOK, tree)
 }
 
 // Constants for file upload
 const (
 	maxFileSize = 50 << 20 // 50 MB
-	uploadsDir  = "storage/uploads"
 )
 
 // Allowed file extensions and their MIME types
@@ -216,51 +360,70 @@ func sanitizeFileName(filename string) string {
 	return filename
 }
 
-// generateUniqueFileName generates a unique filename to avoid conflicts
-func generateUniqueFileName(originalName string) string {
-	ext := filepath.Ext(originalName)
-	nameWithoutExt := strings.TrimSuffix(originalName, ext)
-	timestamp := time.Now().UnixNano()
-	return fmt.Sprintf("%s_%d%s", sanitizeFileName(nameWithoutExt), timestamp, ext)
+// matchContentType returns the MIME type allowed for the extension of
+// fileName that the detected type matches, directly or through a more
+// generic parent type (e.g. CSV is plain text). Text types keep the
+// detected charset.
+func matchContentType(detected *mimetype.MIME, fileName string) (string, bool) {
+	allowed := allowedExtensions[strings.ToLower(filepath.Ext(fileName))]
+	for m := detected; m != nil; m = m.Parent() {
+		for _, contentType := range allowed {
+			if !m.Is(contentType) {
+				continue
+			}
+			if strings.HasPrefix(contentType, "text/") {
+				if _, params, err := mime.ParseMediaType(detected.String()); err == nil && params["charset"] != "" {
+					return contentType + "; charset=" + params["charset"], true
+				}
+			}
+			return contentType, true
+		}
+	}
+	return "", false
 }
 
-// getContentType returns the content type for a file extension
-func getContentType(filename string) string {
-	ext := strings.ToLower(filepath.Ext(filename))
-	switch ext {
-	case ".pdf":
-		return "application/pdf"
-	case ".doc":
-		return "application/msword"
-	case ".docx":
-		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
-	case ".ppt":
-		return "application/vnd.ms-powerpoint"
-	case ".pptx":
-		return "application/vnd.openxmlformats-officedocument.presentationml.presentation"
-	case ".xls":
-		return "application/vnd.ms-excel"
-	case ".xlsx":
-		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
-	case ".txt":
-		return "text/plain"
-	case ".jpg", ".jpeg":
-		return "image/jpeg"
-	case ".png":
-		return "image/png"
-	case ".gif":
-		return "image/gif"
-	case ".mp4":
-		return "video/mp4"
-	case ".mp3":
-		return "audio/mpeg"
-	default:
-		return "application/octet-stream"
+// contentMismatchError is returned when a file's content does not match its extension
+func contentMismatchError(fileName string) error {
+	return validationError{fmt.Sprintf("O conteúdo do arquivo não corresponde à extensão %s", strings.ToLower(filepath.Ext(fileName)))}
+}
+
+// verifiedUpload checks that filePath is one of the user's uploads and that
+// its content matches the extension of fileName. It returns the cleaned
+// path and the verified content type.
+func verifiedUpload(userID int64, filePath, fileName string) (string, string, error) {
+	cleanPath, err := storage.CheckUpload(userID, filePath)
+	if err != nil {
+		return "", "", treeError(err)
 	}
+	detected, err := mimetype.DetectFile(cleanPath)
+	if err != nil {
+		return "", "", err
+	}
+	contentType, ok := matchContentType(detected, fileName)
+	if !ok {
+		return "", "", contentMismatchError(fileName)
+	}
+	return cleanPath, contentType, nil
+}
+
+// servedContentType returns the type a material file is served with.
+// Materials created before content validation are sniffed on the fly and
+// served as a download when their content does not match.
+func servedContentType(node *models.MaterialNode, path string) string {
+	if node.ContentType != "" {
+		return node.ContentType
+	}
+	if detected, err := mimetype.DetectFile(path); err == nil {
+		if contentType, ok := matchContentType(detected, node.FileName); ok {
+			return contentType
+		}
+	}
+	return "application/octet-stream"
 }
 
 // HandleUploadFile handles file upload
 func HandleUploadFile(c *gin.Context) {
+	userID := middleware.GetUserID(c)
 	// Parse multipart form with size limit
 	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFileSize)
 
@@ -284,117 +447,117 @@ func HandleUploadFile(c *gin.Context) {
 		return
 	}
 
-	// Ensure uploads directory exists
-	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar diretório de uploads"})
+	// Validate the content against the extension
+	detected, err := mimetype.DetectReader(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Erro ao receber arquivo"})
 		return
 	}
-
-	// Generate unique filename
-	uniqueFileName := generateUniqueFileName(header.Filename)
-	filePath := filepath.Join(uploadsDir, uniqueFileName)
-
-	// Create the file
-	dst, err := os.Create(filePath)
-	if err != nil {
+	contentType, ok := matchContentType(detected, header.Filename)
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": contentMismatchError(header.Filename).Error()})
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar arquivo"})
 		return
 	}
-	defer dst.Close()
 
-	// Copy file content
-	written, err := io.Copy(dst, file)
+	if err := storage.CheckUploadQuota(userID, header.Size); err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	// Identical files share one stored copy
+	filePath, written, err := storage.StoreUpload(userID, file)
 	if err != nil {
-		// Clean up partial file
-		_ = os.Remove(filePath)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar arquivo"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"filePath": filePath,
-		"fileName": header.Filename,
-		"fileSize": written,
+		"filePath":    filePath,
+		"fileName":    sanitizeFileName(header.Filename),
+		"fileSize":    written,
+		"contentType": contentType,
 	})
 }
 
 // HandleDownloadFile handles file download
 func HandleDownloadFile(c *gin.Context) {
-	materialID := c.Param("id")
-
-	tree, err := storage.LoadMaterials()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+	access, ok := materialAccess(c, c.Param("id"), models.RoleViewer)
+	if !ok {
 		return
 	}
+	serveMaterial(c, access.OwnerID, c.Param("id"), 0, "attachment")
+}
 
-	node := storage.FindNodeByID(tree.Root, materialID)
-	if node == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Material não encontrado"})
+// HandleViewFile handles inline file viewing. Range requests let video and
+// audio players stream and seek without downloading the whole file.
+func HandleViewFile(c *gin.Context) {
+	access, ok := materialAccess(c, c.Param("id"), models.RoleViewer)
+	if !ok {
 		return
 	}
+	serveMaterial(c, access.OwnerID, c.Param("id"), 0, "inline")
+}
 
-	if node.Type != "material" || !node.IsFile {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Este material não é um arquivo"})
+// serveMaterial sends the file of an uploaded material of userID, who owns
+// it, with the given Content-Disposition. A version other than 0 selects a
+// previous file of the material. Range, If-Range and conditional requests are answered from
+// the ETag and modification time of the file.
+func serveMaterial(c *gin.Context, userID int64, materialID string, version int, disposition string) {
+	node, ok := findFileMaterial(c, userID, materialID)
+	if !ok {
 		return
 	}
+	if version != 0 && version != max(node.Version, 1) {
+		previous := storage.FindFileVersion(&node, version)
+		if previous == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Versão não encontrada"})
+			return
+		}
+		node.FilePath = previous.FilePath
+		node.FileName = previous.FileName
+		node.FileSize = previous.FileSize
+		node.ContentType = previous.ContentType
+	}
 
 	// Validate file path to prevent path traversal
 	cleanPath := filepath.Clean(node.FilePath)
-	if !strings.HasPrefix(cleanPath, uploadsDir) {
+	if !strings.HasPrefix(cleanPath, storage.UserUploadsDir(userID)+string(filepath.Separator)) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso não autorizado"})
 		return
 	}
 
-	// Check if file exists
-	if _, err := os.Stat(cleanPath); os.IsNotExist(err) {
+	file, err := os.Open(cleanPath)
+	if os.IsNotExist(err) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Arquivo não encontrado no servidor"})
 		return
 	}
-
-	// Set headers for download
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", node.FileName))
-	c.Header("Content-Type", getContentType(node.FileName))
-	c.File(cleanPath)
-}
-
-// HandleViewFile handles inline file viewing
-func HandleViewFile(c *gin.Context) {
-	materialID := c.Param("id")
-
-	tree, err := storage.LoadMaterials()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao abrir arquivo"})
 		return
 	}
-
-	node := storage.FindNodeByID(tree.Root, materialID)
-	if node == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Material não encontrado"})
-		return
-	}
-
-	if node.Type != "material" || !node.IsFile {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Este material não é um arquivo"})
-		return
-	}
-
-	// Validate file path to prevent path traversal
-	cleanPath := filepath.Clean(node.FilePath)
-	if !strings.HasPrefix(cleanPath, uploadsDir) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso não autorizado"})
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao abrir arquivo"})
 		return
 	}
 
-	// Check if file exists
-	if _, err := os.Stat(cleanPath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Arquivo não encontrado no servidor"})
-		return
+	contentType := servedContentType(&node, cleanPath)
+	if contentType == "application/octet-stream" {
+		disposition = "attachment"
 	}
-
-	// Set headers for inline viewing
-	contentType := getContentType(node.FileName)
-	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", node.FileName))
+	// FormatMediaType quotes the name and encodes non-ASCII characters, so a
+	// stored name cannot break out of the header parameter
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": node.FileName}))
 	c.Header("Content-Type", contentType)
-	c.File(cleanPath)
+	c.Header("X-Content-Type-Options", "nosniff")
+	// Uploads are named by the SHA-256 of their content, so the name is a
+	// strong validator; browsers revalidate instead of reusing stale media
+	c.Header("ETag", `"`+filepath.Base(cleanPath)+`"`)
+	c.Header("Cache-Control", "private, no-cache")
+	http.ServeContent(c.Writer, c.Request, node.FileName, info.ModTime(), file)
 }
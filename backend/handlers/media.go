@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/storage"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleGetMediaPosition returns where the user stopped playing a material.
+// Positions in shared materials are kept per user.
+func HandleGetMediaPosition(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	access, ok := materialAccess(c, c.Param("id"), models.RoleViewer)
+	if !ok {
+		return
+	}
+	node, ok := findFileMaterial(c, access.OwnerID, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	data, err := storage.LoadMediaProgress(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar posição de reprodução"})
+		return
+	}
+	c.JSON(http.StatusOK, data.Positions[node.ID])
+}
+
+// HandleSaveMediaPosition stores where the user stopped playing a material
+func HandleSaveMediaPosition(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	access, ok := materialAccess(c, c.Param("id"), models.RoleViewer)
+	if !ok {
+		return
+	}
+	node, ok := findFileMaterial(c, access.OwnerID, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req models.MediaPositionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+	if !validPlaybackTime(*req.Seconds) || !validPlaybackTime(req.Duration) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Posição de reprodução inválida"})
+		return
+	}
+
+	position := models.MediaPosition{
+		Seconds:   *req.Seconds,
+		Duration:  req.Duration,
+		UpdatedAt: time.Now().Format(time.RFC3339),
+	}
+	_, err := storage.UpdateMediaProgress(userID, func(data *models.MediaProgressData) error {
+		data.Positions[node.ID] = position
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar posição de reprodução"})
+		return
+	}
+	c.JSON(http.StatusOK, position)
+}
+
+// validPlaybackTime reports whether seconds is a usable media time
+func validPlaybackTime(seconds float64) bool {
+	return seconds >= 0 && !math.IsInf(seconds, 0) && !math.IsNaN(seconds)
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"studybuddy/diff"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// noteVersions returns the current note and its previous versions. The
+// current note is numbered after the last previous version.
+func noteVersions(c *gin.Context, userID int64, noteID int64) (models.NoteRevision, []models.NoteRevision, bool) {
+	data, err := storage.LoadData(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler dados"})
+		return models.NoteRevision{}, nil, false
+	}
+	revisions, err := storage.LoadNoteHistory(userID, noteID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler histórico"})
+		return models.NoteRevision{}, nil, false
+	}
+
+	for _, note := range data.Notes {
+		if note.ID == noteID {
+			current := models.NoteRevision{Revision: 1, Title: note.Title, Content: note.Content, Subject: note.Subject}
+			if len(revisions) > 0 {
+				current.Revision = revisions[len(revisions)-1].Revision + 1
+			}
+			return current, revisions, true
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Anotação não encontrada"})
+	return models.NoteRevision{}, nil, false
+}
+
+// findNoteRevision returns a version of a note by number, including the current one
+func findNoteRevision(current models.NoteRevision, revisions []models.NoteRevision, number int) (models.NoteRevision, bool) {
+	if number == current.Revision {
+		return current, true
+	}
+	for _, revision := range revisions {
+		if revision.Revision == number {
+			return revision, true
+		}
+	}
+	return models.NoteRevision{}, false
+}
+
+// HandleListNoteHistory returns the previous versions of a note, oldest first
+func HandleListNoteHistory(c *gin.Context) {
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+	current, revisions, ok := noteVersions(c, middleware.GetUserID(c), id)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"current": current.Revision, "revisions": revisions})
+}
+
+// HandleDiffNote compares two versions of a note line by line. from
+// defaults to the version before to, and to defaults to the current note.
+func HandleDiffNote(c *gin.Context) {
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+	current, revisions, ok := noteVersions(c, middleware.GetUserID(c), id)
+	if !ok {
+		return
+	}
+
+	to := current.Revision
+	if value := c.Query("to"); value != "" {
+		var err error
+		if to, err = strconv.Atoi(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Versão inválida"})
+			return
+		}
+	}
+	from := to - 1
+	if value := c.Query("from"); value != "" {
+		var err error
+		if from, err = strconv.Atoi(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Versão inválida"})
+			return
+		}
+	}
+
+	newer, ok := findNoteRevision(current, revisions, to)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Versão não encontrada"})
+		return
+	}
+	older, ok := findNoteRevision(current, revisions, from)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Versão não encontrada"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":  older.Revision,
+		"to":    newer.Revision,
+		"title": gin.H{"from": older.Title, "to": newer.Title},
+		"lines": diff.Lines(older.Content, newer.Content),
+	})
+}
+
+// HandleRestoreNoteRevision makes a previous version of a note current
+// again. The replaced content is kept in the history.
+func HandleRestoreNoteRevision(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+	number, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Versão inválida"})
+		return
+	}
+
+	revisions, err := storage.LoadNoteHistory(userID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler histórico"})
+		return
+	}
+	var restored *models.NoteRevision
+	for i := range revisions {
+		if revisions[i].Revision == number {
+			restored = &revisions[i]
+		}
+	}
+	if restored == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Versão não encontrada"})
+		return
+	}
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	var note models.Note
+	updated, err := storage.UpdateData(userID, revision, func(data *models.AppData) error {
+		for i := range data.Notes {
+			if data.Notes[i].ID == id {
+				data.Notes[i].Title = restored.Title
+				data.Notes[i].Content = restored.Content
+				data.Notes[i].Subject = restored.Subject
+				note = data.Notes[i]
+				return nil
+			}
+		}
+		return storage.ErrNotFound
+	})
+	if err != nil {
+		respondDataError(c, err, "Anotação não encontrada")
+		return
+	}
+	setRevisionHeader(c, updated.Revision)
+
+	c.JSON(http.StatusOK, note)
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// applyNoteRequest copies the fields present in req into note
+func applyNoteRequest(note *models.Note, req models.NoteRequest) error {
+	if req.Title != nil {
+		if err := requireTitle(req.Title); err != nil {
+			return err
+		}
+		note.Title = strings.TrimSpace(*req.Title)
+	}
+	if req.Content != nil {
+		note.Content = *req.Content
+	}
+	if req.Subject != nil {
+		note.Subject = *req.Subject
+	}
+	if req.Date != nil {
+		note.Date = *req.Date
+	}
+	return nil
+}
+
+// HandleListNotes returns the authenticated user's notes
+func HandleListNotes(c *gin.Context) {
+	data, err := storage.LoadData(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler dados"})
+		return
+	}
+	setRevisionHeader(c, data.Revision)
+	if data.Notes == nil {
+		data.Notes = []models.Note{}
+	}
+	c.JSON(http.StatusOK, data.Notes)
+}
+
+// HandleGetNote returns a single note
+func HandleGetNote(c *gin.Context) {
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+
+	data, err := storage.LoadData(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler dados"})
+		return
+	}
+	setRevisionHeader(c, data.Revision)
+
+	for _, note := range data.Notes {
+		if note.ID == id {
+			c.JSON(http.StatusOK, note)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Anotação não encontrada"})
+}
+
+// HandleCreateNote creates a note with a server generated ID
+func HandleCreateNote(c *gin.Context) {
+	var req models.NoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	var note models.Note
+	updated, err := storage.UpdateData(middleware.GetUserID(c), revision, func(data *models.AppData) error {
+		if err := requireTitle(req.Title); err != nil {
+			return err
+		}
+		if err := applyNoteRequest(&note, req); err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(data.Notes))
+		for _, existing := range data.Notes {
+			ids = append(ids, existing.ID)
+		}
+		note.ID = storage.NextID(ids)
+
+		// Newest notes first, as the frontend displays them
+		data.Notes = append([]models.Note{note}, data.Notes...)
+		return nil
+	})
+	if err != nil {
+		respondDataError(c, err, "Anotação não encontrada")
+		return
+	}
+	setRevisionHeader(c, updated.Revision)
+
+	c.JSON(http.StatusCreated, note)
+}
+
+// HandlePatchNote updates the fields sent for a note
+func HandlePatchNote(c *gin.Context) {
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+
+	var req models.NoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	var note models.Note
+	updated, err := storage.UpdateData(middleware.GetUserID(c), revision, func(data *models.AppData) error {
+		for i := range data.Notes {
+			if data.Notes[i].ID == id {
+				if err := applyNoteRequest(&data.Notes[i], req); err != nil {
+					return err
+				}
+				note = data.Notes[i]
+				return nil
+			}
+		}
+		return storage.ErrNotFound
+	})
+	if err != nil {
+		respondDataError(c, err, "Anotação não encontrada")
+		return
+	}
+	setRevisionHeader(c, updated.Revision)
+
+	c.JSON(http.StatusOK, note)
+}
+
+// HandleDeleteNote moves a note to the trash
+func HandleDeleteNote(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	updated, err := storage.TrashData(userID, revision, func(data *models.AppData) (models.TrashItem, error) {
+		for i, note := range data.Notes {
+			if note.ID == id {
+				data.Notes = append(data.Notes[:i], data.Notes[i+1:]...)
+				return models.TrashItem{Kind: models.TrashNote, Name: note.Title, Note: &note}, nil
+			}
+		}
+		return models.TrashItem{}, storage.ErrNotFound
+	})
+	if err != nil {
+		respondDataError(c, err, "Anotação não encontrada")
+		return
+	}
+	setRevisionHeader(c, updated.Revision)
+
+	c.JSON(http.StatusOK, gin.H{"status": "anotação movida para a lixeira"})
+}
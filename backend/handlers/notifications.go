@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/notify"
+	"studybuddy/storage"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxLeadMinutes limits how early users can be notified (one day)
+const maxLeadMinutes = 24 * 60
+
+// vapidPublicKey is the key browsers need to subscribe to Web Push
+var vapidPublicKey string
+
+// SetVAPIDPublicKey configures the key returned to browsers subscribing to Web Push
+func SetVAPIDPublicKey(key string) {
+	vapidPublicKey = key
+}
+
+// validateNotificationSettings checks the settings sent by the user
+func validateNotificationSettings(settings models.NotificationSettings) error {
+	if settings.LeadMinutes < 0 || settings.LeadMinutes > maxLeadMinutes {
+		return validationError{"A antecedência deve estar entre 0 e 1440 minutos"}
+	}
+	if settings.Timezone != "" {
+		if _, err := time.LoadLocation(settings.Timezone); err != nil {
+			return validationError{"Fuso horário inválido"}
+		}
+	}
+	quiet := settings.QuietHours
+	if (quiet.Start == "") != (quiet.End == "") || !isValidTime(quiet.Start) || !isValidTime(quiet.End) {
+		return validationError{"Horário de silêncio inválido. Informe início e fim no formato HH:MM"}
+	}
+	if settings.Webhook || settings.WebhookURL != "" {
+		parsed, err := url.Parse(settings.WebhookURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return validationError{"URL do webhook inválida"}
+		}
+	}
+	return nil
+}
+
+// HandleGetNotificationSettings returns the user's notification settings
+func HandleGetNotificationSettings(c *gin.Context) {
+	data, err := storage.LoadNotifications(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar notificações"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"settings": data.Settings, "pushSubscriptions": len(data.Subscriptions)})
+}
+
+// HandleUpdateNotificationSettings replaces the user's notification settings
+func HandleUpdateNotificationSettings(c *gin.Context) {
+	var settings models.NotificationSettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	if err := validateNotificationSettings(settings); err != nil {
+		respondDataError(c, err, "Configurações não encontradas")
+		return
+	}
+	// The host is resolved outside the document lock
+	if settings.WebhookURL != "" {
+		if err := notify.CheckPublicURL(c.Request.Context(), settings.WebhookURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "O webhook deve apontar para um endereço público"})
+			return
+		}
+	}
+
+	data, err := storage.UpdateNotifications(middleware.GetUserID(c), func(data *models.NotificationsData) error {
+		data.Settings = settings
+		return nil
+	})
+	if err != nil {
+		respondDataError(c, err, "Configurações não encontradas")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"settings": data.Settings, "pushSubscriptions": len(data.Subscriptions)})
+}
+
+// HandleGetPushKey returns the VAPID public key used to subscribe to Web Push
+func HandleGetPushKey(c *gin.Context) {
+	if vapidPublicKey == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Notificações push não estão disponíveis"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"publicKey": vapidPublicKey})
+}
+
+// HandleSubscribePush stores a browser's push subscription, replacing any
+// previous one with the same endpoint
+func HandleSubscribePush(c *gin.Context) {
+	var req models.PushSubscription
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	endpoint, err := url.Parse(req.Endpoint)
+	p256dh, keyErr := base64.RawURLEncoding.DecodeString(req.Keys.P256dh)
+	auth, authErr := base64.RawURLEncoding.DecodeString(req.Keys.Auth)
+	if err != nil || endpoint.Scheme != "https" || keyErr != nil || len(p256dh) != 65 || authErr != nil || len(auth) != 16 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Inscrição push inválida"})
+		return
+	}
+	if err := notify.CheckPublicURL(c.Request.Context(), req.Endpoint); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "O endpoint push deve apontar para um endereço público"})
+		return
+	}
+
+	req.CreatedAt = time.Now().Format(time.RFC3339)
+	_, err = storage.UpdateNotifications(middleware.GetUserID(c), func(data *models.NotificationsData) error {
+		subscriptions := []models.PushSubscription{req}
+		for _, subscription := range data.Subscriptions {
+			if subscription.Endpoint != req.Endpoint {
+				subscriptions = append(subscriptions, subscription)
+			}
+		}
+		data.Subscriptions = subscriptions
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar inscrição push"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"status": "inscrição salva"})
+}
+
+// HandleUnsubscribePush removes a browser's push subscription
+func HandleUnsubscribePush(c *gin.Context) {
+	var req models.PushUnsubscribeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	if err := storage.RemovePushSubscription(middleware.GetUserID(c), req.Endpoint); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover inscrição push"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "inscrição removida"})
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/recurrence"
+	"studybuddy/storage"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limits of the date range expanded by /api/occurrences
+const (
+	defaultOccurrenceDays = 30
+	maxOccurrenceDays     = 366
+)
+
+// setRecurrence copies the rule sent in a request; an empty frequency removes it
+func setRecurrence(rule **models.Recurrence, req *models.Recurrence) {
+	if req.Frequency == "" {
+		*rule = nil
+		return
+	}
+	copied := *req
+	*rule = &copied
+}
+
+// validateRecurrence checks the rule of an item starting on date
+func validateRecurrence(rule *models.Recurrence, date string) error {
+	if rule == nil {
+		return nil
+	}
+	if err := recurrence.Validate(rule, date); err != nil {
+		return validationError{err.Error()}
+	}
+	return nil
+}
+
+// containsDate reports whether dates contains date
+func containsDate(dates []string, date string) bool {
+	for _, existing := range dates {
+		if existing == date {
+			return true
+		}
+	}
+	return false
+}
+
+// parseOccurrenceDate reads the :date path parameter, writing an error response if invalid
+func parseOccurrenceDate(c *gin.Context) (string, bool) {
+	date := c.Param("date")
+	if !isValidDate(date) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data inválida. Use o formato AAAA-MM-DD"})
+		return "", false
+	}
+	return date, true
+}
+
+// HandleListOccurrences expands events and reminders into their occurrences
+// between ?from= and ?to= (YYYY-MM-DD, inclusive). The range defaults to the
+// next 30 days and is limited to 366 days.
+func HandleListOccurrences(c *gin.Context) {
+	from, to := c.Query("from"), c.Query("to")
+	if from == "" {
+		from = time.Now().Format("2006-01-02")
+	}
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data inválida. Use o formato AAAA-MM-DD"})
+		return
+	}
+	end := start.AddDate(0, 0, defaultOccurrenceDays-1)
+	if to != "" {
+		if end, err = time.Parse("2006-01-02", to); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Data inválida. Use o formato AAAA-MM-DD"})
+			return
+		}
+	}
+	if end.Before(start) || end.Sub(start) >= maxOccurrenceDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "O período deve ter entre 1 e 366 dias"})
+		return
+	}
+
+	data, err := storage.LoadData(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler dados"})
+		return
+	}
+	setRevisionHeader(c, data.Revision)
+
+	occurrences := make([]models.Occurrence, 0)
+	for _, event := range data.Events {
+		for _, date := range recurrence.Occurrences(event.Recurrence, event.Date, start, end) {
+			occurrences = append(occurrences, models.Occurrence{
+				Type:        "event",
+				ID:          event.ID,
+				Title:       event.Title,
+				Date:        date,
+				Time:        event.Time,
+				Description: event.Description,
+				Recurring:   event.Recurrence != nil,
+			})
+		}
+	}
+	for _, reminder := range data.Reminders {
+		for _, date := range recurrence.Occurrences(reminder.Recurrence, reminder.Date, start, end) {
+			occurrences = append(occurrences, models.Occurrence{
+				Type:      "reminder",
+				ID:        reminder.ID,
+				Title:     reminder.Title,
+				Date:      date,
+				Time:      reminder.Time,
+				Priority:  reminder.Priority,
+				Completed: reminder.Completed || containsDate(reminder.CompletedDates, date),
+				Recurring: reminder.Recurrence != nil,
+			})
+		}
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		if occurrences[i].Date != occurrences[j].Date {
+			return occurrences[i].Date < occurrences[j].Date
+		}
+		return occurrences[i].Time < occurrences[j].Time
+	})
+
+	c.JSON(http.StatusOK, occurrences)
+}
+
+// HandleSetReminderOccurrence marks one occurrence of a reminder as
+// completed or pending. For a single reminder it sets Completed.
+func HandleSetReminderOccurrence(c *gin.Context) {
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+	date, ok := parseOccurrenceDate(c)
+	if !ok {
+		return
+	}
+
+	var req models.OccurrenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	var reminder models.Reminder
+	updated, err := storage.UpdateData(middleware.GetUserID(c), revision, func(data *models.AppData) error {
+		for i := range data.Reminders {
+			if data.Reminders[i].ID != id {
+				continue
+			}
+			current := &data.Reminders[i]
+			if !recurrence.IsOccurrence(current.Recurrence, current.Date, date) {
+				return validationError{"O lembrete não ocorre nesta data"}
+			}
+
+			if current.Recurrence == nil {
+				current.Completed = *req.Completed
+			} else if *req.Completed && !containsDate(current.CompletedDates, date) {
+				current.CompletedDates = append(current.CompletedDates, date)
+				sort.Strings(current.CompletedDates)
+			} else if !*req.Completed {
+				dates := make([]string, 0, len(current.CompletedDates))
+				for _, existing := range current.CompletedDates {
+					if existing != date {
+						dates = append(dates, existing)
+					}
+				}
+				current.CompletedDates = dates
+			}
+			reminder = *current
+			return nil
+		}
+		return storage.ErrNotFound
+	})
+	if err != nil {
+		respondDataError(c, err, "Lembrete não encontrado")
+		return
+	}
+	setRevisionHeader(c, updated.Revision)
+
+	c.JSON(http.StatusOK, reminder)
+}
+
+// addException skips one occurrence of a recurring item
+func addException(rule *models.Recurrence, start, date string) error {
+	if rule == nil {
+		return validationError{"O item não se repete. Remova-o diretamente"}
+	}
+	if !recurrence.IsOccurrence(rule, start, date) {
+		return validationError{"Não há ocorrência nesta data"}
+	}
+	rule.Exceptions = append(rule.Exceptions, date)
+	sort.Strings(rule.Exceptions)
+	return nil
+}
+
+// HandleSkipEventOccurrence removes one occurrence of a recurring event
+func HandleSkipEventOccurrence(c *gin.Context) {
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+	date, ok := parseOccurrenceDate(c)
+	if !ok {
+		return
+	}
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	var event models.Event
+	updated, err := storage.UpdateData(middleware.GetUserID(c), revision, func(data *models.AppData) error {
+		for i := range data.Events {
+			if data.Events[i].ID == id {
+				if err := addException(data.Events[i].Recurrence, data.Events[i].Date, date); err != nil {
+					return err
+				}
+				event = data.Events[i]
+				return nil
+			}
+		}
+		return storage.ErrNotFound
+	})
+	if err != nil {
+		respondDataError(c, err, "Evento não encontrado")
+		return
+	}
+	setRevisionHeader(c, updated.Revision)
+
+	c.JSON(http.StatusOK, event)
+}
+
+// HandleSkipReminderOccurrence removes one occurrence of a recurring reminder
+func HandleSkipReminderOccurrence(c *gin.Context) {
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+	date, ok := parseOccurrenceDate(c)
+	if !ok {
+		return
+	}
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	var reminder models.Reminder
+	updated, err := storage.UpdateData(middleware.GetUserID(c), revision, func(data *models.AppData) error {
+		for i := range data.Reminders {
+			if data.Reminders[i].ID == id {
+				if err := addException(data.Reminders[i].Recurrence, data.Reminders[i].Date, date); err != nil {
+					return err
+				}
+				reminder = data.Reminders[i]
+				return nil
+			}
+		}
+		return storage.ErrNotFound
+	})
+	if err != nil {
+		respondDataError(c, err, "Lembrete não encontrado")
+		return
+	}
+	setRevisionHeader(c, updated.Revision)
+
+	c.JSON(http.StatusOK, reminder)
+}
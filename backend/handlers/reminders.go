@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// applyReminderRequest validates and copies the fields present in req into reminder
+func applyReminderRequest(reminder *models.Reminder, req models.ReminderRequest) error {
+	if req.Title != nil {
+		if err := requireTitle(req.Title); err != nil {
+			return err
+		}
+		reminder.Title = strings.TrimSpace(*req.Title)
+	}
+	if req.Date != nil {
+		if !isValidDate(*req.Date) {
+			return validationError{"Data inválida. Use o formato AAAA-MM-DD"}
+		}
+		reminder.Date = *req.Date
+	}
+	if req.Time != nil {
+		if !isValidTime(*req.Time) {
+			return validationError{"Horário inválido. Use o formato HH:MM"}
+		}
+		reminder.Time = *req.Time
+	}
+	if req.Priority != nil {
+		if !validPriorities[*req.Priority] {
+			return validationError{"Prioridade inválida. Use baixa, media ou alta"}
+		}
+		reminder.Priority = *req.Priority
+	}
+	if req.Completed != nil {
+		reminder.Completed = *req.Completed
+	}
+	if req.Recurrence != nil {
+		setRecurrence(&reminder.Recurrence, req.Recurrence)
+	}
+	return validateRecurrence(reminder.Recurrence, reminder.Date)
+}
+
+// HandleListReminders returns the authenticated user's reminders
+func HandleListReminders(c *gin.Context) {
+	data, err := storage.LoadData(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler dados"})
+		return
+	}
+	setRevisionHeader(c, data.Revision)
+	if data.Reminders == nil {
+		data.Reminders = []models.Reminder{}
+	}
+	c.JSON(http.StatusOK, data.Reminders)
+}
+
+// HandleGetReminder returns a single reminder
+func HandleGetReminder(c *gin.Context) {
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+
+	data, err := storage.LoadData(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler dados"})
+		return
+	}
+	setRevisionHeader(c, data.Revision)
+
+	for _, reminder := range data.Reminders {
+		if reminder.ID == id {
+			c.JSON(http.StatusOK, reminder)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Lembrete não encontrado"})
+}
+
+// HandleCreateReminder creates a reminder with a server generated ID
+func HandleCreateReminder(c *gin.Context) {
+	var req models.ReminderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	reminder := models.Reminder{Priority: "media"}
+	updated, err := storage.UpdateData(middleware.GetUserID(c), revision, func(data *models.AppData) error {
+		if err := requireTitle(req.Title); err != nil {
+			return err
+		}
+		if req.Date == nil {
+			return validationError{"A data é obrigatória"}
+		}
+		if err := applyReminderRequest(&reminder, req); err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(data.Reminders))
+		for _, existing := range data.Reminders {
+			ids = append(ids, existing.ID)
+		}
+		reminder.ID = storage.NextID(ids)
+
+		data.Reminders = append(data.Reminders, reminder)
+		return nil
+	})
+	if err != nil {
+		respondDataError(c, err, "Lembrete não encontrado")
+		return
+	}
+	setRevisionHeader(c, updated.Revision)
+
+	c.JSON(http.StatusCreated, reminder)
+}
+
+// HandlePatchReminder updates the fields sent for a reminder
+func HandlePatchReminder(c *gin.Context) {
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+
+	var req models.ReminderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	var reminder models.Reminder
+	updated, err := storage.UpdateData(middleware.GetUserID(c), revision, func(data *models.AppData) error {
+		for i := range data.Reminders {
+			if data.Reminders[i].ID == id {
+				if err := applyReminderRequest(&data.Reminders[i], req); err != nil {
+					return err
+				}
+				reminder = data.Reminders[i]
+				return nil
+			}
+		}
+		return storage.ErrNotFound
+	})
+	if err != nil {
+		respondDataError(c, err, "Lembrete não encontrado")
+		return
+	}
+	setRevisionHeader(c, updated.Revision)
+
+	c.JSON(http.StatusOK, reminder)
+}
+
+// HandleDeleteReminder deletes a reminder by ID
+func HandleDeleteReminder(c *gin.Context) {
+	id, ok := parseItemID(c)
+	if !ok {
+		return
+	}
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	updated, err := storage.UpdateData(middleware.GetUserID(c), revision, func(data *models.AppData) error {
+		for i, reminder := range data.Reminders {
+			if reminder.ID == id {
+				data.Reminders = append(data.Reminders[:i], data.Reminders[i+1:]...)
+				return nil
+			}
+		}
+		return storage.ErrNotFound
+	})
+	if err != nil {
+		respondDataError(c, err, "Lembrete não encontrado")
+		return
+	}
+	setRevisionHeader(c, updated.Revision)
+
+	c.JSON(http.StatusOK, gin.H{"status": "lembrete removido"})
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/search"
+	"studybuddy/storage"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limits of the number of search results
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// searchTypes are the document types accepted by the ?type= filter
+var searchTypes = map[string]bool{
+	"note":     true,
+	"event":    true,
+	"reminder": true,
+	"folder":   true,
+	"material": true,
+}
+
+// cachedIndex is a user's search index and the revisions it was built from
+type cachedIndex struct {
+	dataRevision      int64
+	materialsRevision int64
+	textsRevision     int64
+	index             *search.Index
+}
+
+var (
+	// searchIndexes keeps each user's index until their data, materials or
+	// extracted texts change
+	searchIndexes = make(map[int64]cachedIndex)
+	searchMutex   sync.Mutex
+)
+
+// userSearchIndex returns the user's index, rebuilding it when the data,
+// materials or extracted texts revision changed since it was built
+func userSearchIndex(userID int64) (*search.Index, error) {
+	data, err := storage.LoadData(userID)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := storage.LoadMaterials(userID)
+	if err != nil {
+		return nil, err
+	}
+	texts, err := storage.LoadMaterialTexts(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	searchMutex.Lock()
+	defer searchMutex.Unlock()
+
+	cached, ok := searchIndexes[userID]
+	if ok && cached.dataRevision == data.Revision && cached.materialsRevision == tree.Revision && cached.textsRevision == texts.Revision {
+		return cached.index, nil
+	}
+
+	extracted := make(map[string]string)
+	for id, text := range texts.Texts {
+		if text.Status != models.TextDone {
+			continue
+		}
+		if extracted[id], err = storage.LoadMaterialText(userID, id); err != nil {
+			return nil, err
+		}
+	}
+
+	index := search.Build(searchDocuments(data, tree, extracted))
+	searchIndexes[userID] = cachedIndex{
+		dataRevision:      data.Revision,
+		materialsRevision: tree.Revision,
+		textsRevision:     texts.Revision,
+		index:             index,
+	}
+	return index, nil
+}
+
+// searchDocuments lists the searchable items of a user. Uploaded materials
+// are searchable by the text extracted from their files, keyed by node ID.
+func searchDocuments(data models.AppData, tree *models.MaterialsTree, extracted map[string]string) []search.Document {
+	var docs []search.Document
+	for _, note := range data.Notes {
+		docs = append(docs, search.Document{
+			Type:    "note",
+			ID:      strconv.FormatInt(note.ID, 10),
+			Title:   note.Title,
+			Text:    note.Content,
+			Subject: note.Subject,
+			Date:    note.Date,
+		})
+	}
+	for _, event := range data.Events {
+		docs = append(docs, search.Document{
+			Type:  "event",
+			ID:    strconv.FormatInt(event.ID, 10),
+			Title: event.Title,
+			Text:  event.Description,
+			Date:  event.Date,
+		})
+	}
+	for _, reminder := range data.Reminders {
+		docs = append(docs, search.Document{
+			Type:  "reminder",
+			ID:    strconv.FormatInt(reminder.ID, 10),
+			Title: reminder.Title,
+			Date:  reminder.Date,
+		})
+	}
+
+	var walk func(node *models.MaterialNode)
+	walk = func(node *models.MaterialNode) {
+		if node == nil {
+			return
+		}
+		if node.ParentID != "" {
+			text := node.Description
+			if extracted[node.ID] != "" {
+				text = strings.TrimSpace(text + "\n" + extracted[node.ID])
+			}
+			docs = append(docs, search.Document{
+				Type:  node.Type,
+				ID:    node.ID,
+				Title: node.Name,
+				Text:  text,
+				Date:  node.DateAdded,
+			})
+		}
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(tree.Root)
+	return docs
+}
+
+// HandleSearch searches the user's notes, events, reminders and materials.
+// ?q= is required; ?type= (comma separated), ?subject= and ?limit= are optional.
+func HandleSearch(c *gin.Context) {
+	query := search.Query{
+		Text:    strings.TrimSpace(c.Query("q")),
+		Subject: c.Query("subject"),
+		Types:   make(map[string]bool),
+	}
+	limit := defaultSearchLimit
+	if query.Text == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe o termo de busca"})
+		return
+	}
+	if value := c.Query("type"); value != "" {
+		for _, kind := range strings.Split(value, ",") {
+			kind = strings.TrimSpace(kind)
+			if !searchTypes[kind] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Tipo inválido. Use note, event, reminder, folder ou material"})
+				return
+			}
+			query.Types[kind] = true
+		}
+	}
+	if value := c.Query("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "O limite deve estar entre 1 e 100"})
+			return
+		}
+	}
+
+	index, err := userSearchIndex(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler dados"})
+		return
+	}
+
+	results := index.Search(query)
+	total := len(results)
+	if total > limit {
+		results = results[:limit]
+	}
+	c.JSON(http.StatusOK, gin.H{"query": query.Text, "total": total, "results": results})
+}
@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/preview"
+	"studybuddy/storage"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limits on guessing the password of public links. Wrong passwords are
+// counted per link, and every attempt per client IP, as each one runs a
+// bcrypt comparison.
+const (
+	linkAttemptWindow = 15 * time.Minute
+	maxLinkFailures   = 10 // Wrong passwords per link in a window
+	maxLinkIPAttempts = 30 // Password attempts per IP in a window
+)
+
+var (
+	linkFailures   = newAttemptLimiter(maxLinkFailures)
+	linkIPAttempts = newAttemptLimiter(maxLinkIPAttempts)
+)
+
+// attemptLimiter counts attempts per key in fixed windows of
+// linkAttemptWindow
+type attemptLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	windows map[string]attemptWindow
+}
+
+// attemptWindow is the number of attempts of a key since start
+type attemptWindow struct {
+	start time.Time
+	count int
+}
+
+func newAttemptLimiter(limit int) *attemptLimiter {
+	return &attemptLimiter{limit: limit, windows: make(map[string]attemptWindow)}
+}
+
+// retryAfter returns how long key must wait before another attempt, or 0
+// when it may try now
+func (l *attemptLimiter) retryAfter(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	window, exists := l.windows[key]
+	elapsed := time.Since(window.start)
+	if !exists || elapsed >= linkAttemptWindow || window.count < l.limit {
+		return 0
+	}
+	return linkAttemptWindow - elapsed
+}
+
+// add counts an attempt of key
+func (l *attemptLimiter) add(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	// Windows that ended are dropped once in a while to bound the memory
+	if len(l.windows) >= 4096 {
+		for other, window := range l.windows {
+			if now.Sub(window.start) >= linkAttemptWindow {
+				delete(l.windows, other)
+			}
+		}
+	}
+	window, exists := l.windows[key]
+	if !exists || now.Sub(window.start) >= linkAttemptWindow {
+		window = attemptWindow{start: now}
+	}
+	window.count++
+	l.windows[key] = window
+}
+
+// parseLinkExpiry reads the expiry of a new public link, either an RFC 3339
+// time or a date that is valid until the end of that day. An empty value
+// never expires.
+func parseLinkExpiry(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if expiresAt, err := time.Parse(time.RFC3339, value); err == nil {
+		return expiresAt, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return day.AddDate(0, 0, 1), nil
+}
+
+// respondShareLinkError writes the response for a failed public link operation
+func respondShareLinkError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrShareLinkNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Link não encontrado, expirado ou revogado"})
+	case errors.Is(err, storage.ErrShareLinkPassword):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Senha incorreta"})
+	case errors.Is(err, storage.ErrShareLinkExhausted):
+		c.JSON(http.StatusGone, gin.H{"error": "Este link atingiu o limite de downloads"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao abrir link"})
+	}
+}
+
+// HandleCreateShareLink creates a public link to a folder or material of
+// the authenticated user, with an optional expiry date, download limit and
+// password. The URL is only returned here, as only a hash of its token is
+// stored.
+func HandleCreateShareLink(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	nodeID := c.Param("id")
+
+	// The body is optional: a link without limits needs no options
+	var req models.CreateShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+	expiresAt, err := parseLinkExpiry(req.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data de expiração inválida"})
+		return
+	}
+	if !expiresAt.IsZero() && !expiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A data de expiração deve estar no futuro"})
+		return
+	}
+	if req.MaxDownloads < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limite de downloads inválido"})
+		return
+	}
+
+	node, ok, err := storage.FindMaterial(userID, nodeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Nó não encontrado"})
+		return
+	}
+	if node.ID == "root" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Não é possível compartilhar a pasta raiz"})
+		return
+	}
+
+	token, link, err := storage.CreateShareLink(userID, nodeID, expiresAt, req.MaxDownloads, req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar link"})
+		return
+	}
+	link.Name = node.Name
+	link.URL = "/s/" + token
+	c.JSON(http.StatusCreated, link)
+}
+
+// HandleListShareLinks returns the authenticated user's public links that
+// did not expire
+func HandleListShareLinks(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	links, err := storage.ListShareLinks(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar links"})
+		return
+	}
+	for i := range links {
+		if node, ok, err := storage.FindMaterial(userID, links[i].NodeID); err == nil && ok {
+			links[i].Name = node.Name
+		}
+	}
+	c.JSON(http.StatusOK, links)
+}
+
+// HandleRevokeShareLink deletes a public link of the authenticated user
+func HandleRevokeShareLink(c *gin.Context) {
+	if err := storage.RevokeShareLink(middleware.GetUserID(c), c.Param("id")); err != nil {
+		respondShareLinkError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "link revogado"})
+}
+
+// HandleShareLinkPage serves the page that opens a public link
+func HandleShareLinkPage(c *gin.Context) {
+	c.File("./static/share.html")
+}
+
+// startsDownload reports whether a request for a file starts a new
+// download, rather than being a HEAD request or continuing one with a range
+// request, as media players and resumed downloads do
+func startsDownload(c *gin.Context) bool {
+	if c.Request.Method != http.MethodGet {
+		return false
+	}
+	ranges := c.GetHeader("Range")
+	return ranges == "" || strings.HasPrefix(ranges, "bytes=0-")
+}
+
+// findShareLink returns the public link of the :token parameter,
+// responding with an error when it does not exist
+func findShareLink(c *gin.Context) (storage.ShareLinkAccess, bool) {
+	link, err := storage.FindShareLink(c.Param("token"))
+	if err != nil {
+		respondShareLinkError(c, err)
+		return link, false
+	}
+	return link, true
+}
+
+// publicNode returns a copy of node and its descendants without the
+// owner's file paths, previous versions and shares
+func publicNode(node *models.MaterialNode) *models.MaterialNode {
+	copied := *node
+	copied.FilePath = ""
+	copied.Versions = nil
+	copied.Shares = nil
+	copied.Children = nil
+	for _, child := range node.Children {
+		copied.Children = append(copied.Children, publicNode(child))
+	}
+	return &copied
+}
+
+// respondShareLinkListing responds with the read-only listing of the node a
+// public link opens. extra is added to the response.
+func respondShareLinkListing(c *gin.Context, link storage.ShareLinkAccess, extra gin.H) {
+	tree, err := storage.LoadMaterials(link.OwnerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+		return
+	}
+	node := storage.FindNodeByID(tree.Root, link.NodeID)
+	if node == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "O item compartilhado não existe mais"})
+		return
+	}
+
+	response := gin.H{"requiresPassword": link.Password, "node": publicNode(node)}
+	if owner, exists := storage.GetUserByID(link.OwnerID); exists {
+		response["ownerName"] = owner.Name
+	}
+	if !link.ExpiresAt.IsZero() {
+		response["expiresAt"] = link.ExpiresAt.Format(time.RFC3339)
+	}
+	for key, value := range extra {
+		response[key] = value
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// HandleGetShareLink returns the listing of a public link. Links with a
+// password only report that the password is required.
+func HandleGetShareLink(c *gin.Context) {
+	link, ok := findShareLink(c)
+	if !ok {
+		return
+	}
+	if link.Password {
+		c.JSON(http.StatusOK, gin.H{"requiresPassword": true})
+		return
+	}
+	respondShareLinkListing(c, link, nil)
+}
+
+// HandleOpenShareLink checks the password of a public link and returns its
+// listing with an access token for the files of the link
+func HandleOpenShareLink(c *gin.Context) {
+	link, ok := findShareLink(c)
+	if !ok {
+		return
+	}
+
+	var req models.OpenShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	if link.Password {
+		ip := c.ClientIP()
+		wait := max(linkFailures.retryAfter(link.LinkID), linkIPAttempts.retryAfter(ip))
+		if wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Muitas tentativas, tente novamente em alguns minutos"})
+			return
+		}
+		linkIPAttempts.add(ip)
+	}
+	if err := storage.CheckShareLinkPassword(c.Param("token"), req.Password); err != nil {
+		if errors.Is(err, storage.ErrShareLinkPassword) {
+			linkFailures.add(link.LinkID)
+		}
+		respondShareLinkError(c, err)
+		return
+	}
+
+	access, expiresAt, err := storage.GenerateLinkAccessToken(link.LinkID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao abrir link"})
+		return
+	}
+	respondShareLinkListing(c, link, gin.H{"access": access, "accessExpiresAt": expiresAt.Format(time.RFC3339)})
+}
+
+// HandleShareLinkFile returns a short-lived signed URL to a file of a
+// public link. The download is counted when the URL is fetched.
+func HandleShareLinkFile(c *gin.Context) {
+	link, ok := findShareLink(c)
+	if !ok {
+		return
+	}
+
+	var req models.ShareLinkFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+	if link.Password {
+		if linkID, err := storage.ParseLinkAccessToken(req.Access); err != nil || linkID != link.LinkID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Digite a senha do link novamente"})
+			return
+		}
+	}
+
+	materialID := c.Param("id")
+	within, err := storage.IsWithinNode(link.OwnerID, link.NodeID, materialID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+		return
+	}
+	if !within {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material não encontrado"})
+		return
+	}
+	node, ok := findFileMaterial(c, link.OwnerID, materialID)
+	if !ok {
+		return
+	}
+
+	if link.Exhausted {
+		respondShareLinkError(c, storage.ErrShareLinkExhausted)
+		return
+	}
+
+	ttl := storage.FileTokenTTL
+	if kind := preview.Kind(node.ContentType); kind == preview.KindVideo || kind == preview.KindAudio {
+		ttl = storage.MediaTokenTTL
+	}
+	token, expiresAt, err := storage.GenerateFileToken(storage.FileToken{
+		UserID:     link.OwnerID,
+		MaterialID: node.ID,
+		Download:   req.Download,
+		LinkID:     link.LinkID,
+	}, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar link do arquivo"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"url":       "/files/" + url.PathEscape(token),
+		"expiresAt": expiresAt.Format(time.RFC3339),
+	})
+}
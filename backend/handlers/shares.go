@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// materialAccess returns who owns the node a request refers to, checking
+// that the user has at least the required role on it. Nodes that are not
+// shared with the user resolve to the user's own tree.
+func materialAccess(c *gin.Context, nodeID, required string) (storage.MaterialAccess, bool) {
+	access, err := storage.ResolveMaterialAccess(middleware.GetUserID(c), nodeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+		return access, false
+	}
+	if !storage.RoleAllows(access.Role, required) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Você tem permissão apenas para visualizar este item"})
+		return access, false
+	}
+	return access, true
+}
+
+// isShared reports whether access is to another user's tree
+func isShared(c *gin.Context, access storage.MaterialAccess) bool {
+	return access.OwnerID != middleware.GetUserID(c)
+}
+
+// respondAccessMaterialsError writes the response for an error returned by
+// storage.UpdateMaterials on the tree of access.OwnerID. Conflicts on a
+// shared tree return only the current revision, not the owner's tree.
+func respondAccessMaterialsError(c *gin.Context, access storage.MaterialAccess, err error) {
+	if !isShared(c, access) || !errors.Is(err, storage.ErrRevisionConflict) {
+		respondMaterialsError(c, err)
+		return
+	}
+	current, loadErr := storage.LoadMaterials(access.OwnerID)
+	if loadErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+		return
+	}
+	setRevisionHeader(c, current.Revision)
+	c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "revision": current.Revision})
+}
+
+// adoptUpload checks an upload of the user for a new material or version
+// and, in a shared folder, copies it to the owner's store. It returns the
+// path to reference and the verified content type.
+func adoptUpload(userID int64, access storage.MaterialAccess, filePath, fileName string) (string, string, error) {
+	cleanPath, contentType, err := verifiedUpload(userID, filePath, fileName)
+	if err != nil || access.OwnerID == userID {
+		return cleanPath, contentType, err
+	}
+	ownerPath, err := storage.AdoptUpload(access.OwnerID, cleanPath)
+	return ownerPath, contentType, err
+}
+
+// HandleGetSharedMaterials returns the virtual folder holding what other
+// users shared with the authenticated user
+func HandleGetSharedMaterials(c *gin.Context) {
+	nodes, err := storage.ListSharedWithMe(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais compartilhados"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":       models.SharedRootID,
+		"name":     "Compartilhados comigo",
+		"type":     "folder",
+		"children": nodes,
+	})
+}
+
+// HandleListShares returns the users a node of the authenticated user is shared with
+func HandleListShares(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	tree, err := storage.LoadMaterials(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+		return
+	}
+	node := storage.FindNodeByID(tree.Root, c.Param("id"))
+	if node == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Nó não encontrado"})
+		return
+	}
+
+	shares := []models.ShareInfo{}
+	for _, share := range node.Shares {
+		user, exists := storage.GetUserByID(share.UserID)
+		if !exists {
+			continue
+		}
+		shares = append(shares, models.ShareInfo{
+			UserID:   user.ID,
+			Email:    user.Email,
+			Name:     user.Name,
+			Role:     share.Role,
+			SharedAt: share.SharedAt,
+		})
+	}
+	setRevisionHeader(c, tree.Revision)
+	c.JSON(http.StatusOK, shares)
+}
+
+// HandleShareNode grants another user, found by email, viewer or editor
+// rights on a folder or material of the authenticated user and everything
+// inside it. Sharing again with the same user changes the role.
+func HandleShareNode(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	nodeID := c.Param("id")
+
+	var req models.ShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+	recipient, exists := storage.GetUser(strings.TrimSpace(req.Email))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+		return
+	}
+	if recipient.ID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Não é possível compartilhar com você mesmo"})
+		return
+	}
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	var share models.Share
+	tree, err := storage.UpdateMaterials(userID, revision, func(tree *models.MaterialsTree) error {
+		node := storage.FindNodeByID(tree.Root, nodeID)
+		if node == nil {
+			return treeError(errors.New("nó não encontrado"))
+		}
+		var err error
+		if share, err = storage.SetShare(node, recipient.ID, req.Role); err != nil {
+			return treeError(err)
+		}
+		return storage.AddSharedRef(recipient.ID, models.SharedRef{OwnerID: userID, NodeID: nodeID})
+	})
+	if err != nil {
+		respondMaterialsError(c, err)
+		return
+	}
+
+	setRevisionHeader(c, tree.Revision)
+	c.JSON(http.StatusOK, models.ShareInfo{
+		UserID:   recipient.ID,
+		Email:    recipient.Email,
+		Name:     recipient.Name,
+		Role:     share.Role,
+		SharedAt: share.SharedAt,
+	})
+}
+
+// HandleRevokeShare removes the access of a user to a shared node. The
+// owner can revoke anyone; the user a node is shared with can only leave
+// the share.
+func HandleRevokeShare(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	nodeID := c.Param("id")
+	recipientID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Usuário inválido"})
+		return
+	}
+
+	access, err := storage.ResolveMaterialAccess(userID, nodeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+		return
+	}
+	if isShared(c, access) && recipientID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Apenas o dono pode alterar o compartilhamento"})
+		return
+	}
+
+	revision := storage.AnyRevision
+	if !isShared(c, access) {
+		var ok bool
+		if revision, ok = ifMatchRevision(c); !ok {
+			return
+		}
+	}
+
+	tree, err := storage.UpdateMaterials(access.OwnerID, revision, func(tree *models.MaterialsTree) error {
+		node := storage.FindNodeByID(tree.Root, nodeID)
+		if node == nil {
+			return treeError(errors.New("nó não encontrado"))
+		}
+		if !storage.RemoveShare(node, recipientID) {
+			return treeError(errors.New("este item não está compartilhado com o usuário"))
+		}
+		return storage.RemoveSharedRef(recipientID, models.SharedRef{OwnerID: access.OwnerID, NodeID: nodeID})
+	})
+	if err != nil {
+		respondAccessMaterialsError(c, access, err)
+		return
+	}
+
+	if !isShared(c, access) {
+		setRevisionHeader(c, tree.Revision)
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "compartilhamento removido"})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"studybuddy/middleware"
+	"studybuddy/stats"
+	"studybuddy/storage"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleGetStats returns the user's study statistics. Day boundaries follow
+// the IANA time zone in ?tz= (default: the server's), and ?days= sets the
+// length of the daily series.
+func HandleGetStats(c *gin.Context) {
+	loc := time.Local
+	if tz := c.Query("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Fuso horário inválido"})
+			return
+		}
+	}
+
+	days := stats.DefaultDays
+	if value := c.Query("days"); value != "" {
+		var err error
+		days, err = strconv.Atoi(value)
+		if err != nil || days < 1 || days > stats.MaxDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "O número de dias deve estar entre 1 e 366"})
+			return
+		}
+	}
+
+	userID := middleware.GetUserID(c)
+	now := time.Now()
+	var input stats.Input
+	var err error
+	// Sessions go first: finishing overdue ones adds to the study log
+	if input.Focus, err = loadFocus(userID, now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar sessões de foco"})
+		return
+	}
+	if input.Data, err = storage.LoadData(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler dados"})
+		return
+	}
+	if input.Flashcards, err = storage.LoadFlashcards(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar flashcards"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats.Compute(input, loc, now, days))
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errSubjectExists stops UpdateData when the subject is already registered,
+// so nothing is saved
+var errSubjectExists = errors.New("Matéria já cadastrada")
+
+// findSubject returns the index of a subject, or -1
+func findSubject(subjects []string, name string) int {
+	for i, subject := range subjects {
+		if subject == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// HandleListSubjects returns the authenticated user's subjects
+func HandleListSubjects(c *gin.Context) {
+	data, err := storage.LoadData(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler dados"})
+		return
+	}
+	setRevisionHeader(c, data.Revision)
+	if data.Subjects == nil {
+		data.Subjects = []string{}
+	}
+	c.JSON(http.StatusOK, data.Subjects)
+}
+
+// HandleCreateSubject adds a subject
+func HandleCreateSubject(c *gin.Context) {
+	var req models.SubjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	updated, err := storage.UpdateData(middleware.GetUserID(c), revision, func(data *models.AppData) error {
+		if findSubject(data.Subjects, name) != -1 {
+			return errSubjectExists
+		}
+		data.Subjects = append(data.Subjects, name)
+		return nil
+	})
+	if errors.Is(err, errSubjectExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		respondDataError(c, err, "Matéria não encontrada")
+		return
+	}
+	setRevisionHeader(c, updated.Revision)
+
+	c.JSON(http.StatusCreated, gin.H{"name": name})
+}
+
+// HandleRenameSubject renames a subject and the notes, decks and focus sessions
+// that reference it. Notes are renamed together with the subject; decks and
+// sessions live in other documents and are updated afterwards, so a failure
+// there leaves them under the old name and is reported as an error.
+func HandleRenameSubject(c *gin.Context) {
+	oldName := c.Param("name")
+
+	var req models.SubjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+	newName := strings.TrimSpace(req.Name)
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	updated, err := storage.UpdateData(userID, revision, func(data *models.AppData) error {
+		index := findSubject(data.Subjects, oldName)
+		if index == -1 {
+			return storage.ErrNotFound
+		}
+		if newName != oldName && findSubject(data.Subjects, newName) != -1 {
+			return validationError{"Já existe uma matéria com esse nome"}
+		}
+
+		data.Subjects[index] = newName
+		for i := range data.Notes {
+			if data.Notes[i].Subject == oldName {
+				data.Notes[i].Subject = newName
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondDataError(c, err, "Matéria não encontrada")
+		return
+	}
+	setRevisionHeader(c, updated.Revision)
+
+	if newName != oldName {
+		if err := renameSubjectReferences(userID, oldName, newName); err != nil {
+			log.Printf("ERROR: Could not rename subject of decks and focus sessions of user %d: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Matéria renomeada, mas não foi possível atualizar os baralhos e sessões de foco"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"name": newName})
+}
+
+// renameSubjectReferences moves the decks and focus sessions of a subject to
+// its new name. Decks named after the subject, as created by subjectDeck, are
+// renamed too so cards derived from notes keep going to the same deck.
+func renameSubjectReferences(userID int64, oldName, newName string) error {
+	_, err := storage.UpdateFlashcards(userID, func(data *models.FlashcardsData) error {
+		for i := range data.Decks {
+			if data.Decks[i].Subject != oldName {
+				continue
+			}
+			data.Decks[i].Subject = newName
+			if data.Decks[i].Name == oldName {
+				data.Decks[i].Name = newName
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = storage.UpdateFocus(userID, func(data *models.FocusData) error {
+		for i := range data.Sessions {
+			if data.Sessions[i].Subject == oldName {
+				data.Sessions[i].Subject = newName
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// HandleDeleteSubject removes a subject. Notes keep their subject text.
+func HandleDeleteSubject(c *gin.Context) {
+	name := c.Param("name")
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	updated, err := storage.UpdateData(middleware.GetUserID(c), revision, func(data *models.AppData) error {
+		index := findSubject(data.Subjects, name)
+		if index == -1 {
+			return storage.ErrNotFound
+		}
+		data.Subjects = append(data.Subjects[:index], data.Subjects[index+1:]...)
+		return nil
+	})
+	if err != nil {
+		respondDataError(c, err, "Matéria não encontrada")
+		return
+	}
+	setRevisionHeader(c, updated.Revision)
+
+	c.JSON(http.StatusOK, gin.H{"status": "matéria removida"})
+}
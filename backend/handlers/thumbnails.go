@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"studybuddy/models"
+	"studybuddy/preview"
+	"studybuddy/storage"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// thumbnailCacheControl makes browsers revalidate thumbnails. A new file
+// version changes a material's thumbnail, and the ETag of the stored file
+// answers unchanged ones with 304.
+const thumbnailCacheControl = "private, no-cache"
+
+// maxThumbnailJobs limits how many thumbnails are generated at the same time
+const maxThumbnailJobs = 2
+
+// thumbnailCall is a thumbnail being generated, shared by the requests for
+// the same file
+type thumbnailCall struct {
+	done chan struct{}
+	path string
+	err  error
+}
+
+// errThumbnailFailed is returned to the requests waiting for a generation
+// that did not finish
+var errThumbnailFailed = errors.New("miniatura não gerada")
+
+var (
+	thumbnailJobs  = make(chan struct{}, maxThumbnailJobs)
+	thumbnailCalls = make(map[string]*thumbnailCall)
+	thumbnailMutex sync.Mutex
+)
+
+// cachedThumbnail returns the cached thumbnail of an uploaded file,
+// generating it on the first request. Concurrent requests for the same file
+// wait for a single generation.
+func cachedThumbnail(userID int64, filePath, contentType string) (string, error) {
+	thumbnail := storage.ThumbnailPath(userID, filePath)
+	if _, err := os.Stat(thumbnail); err == nil {
+		return thumbnail, nil
+	}
+
+	thumbnailMutex.Lock()
+	call, ok := thumbnailCalls[thumbnail]
+	if !ok {
+		call = &thumbnailCall{done: make(chan struct{}), err: errThumbnailFailed}
+		thumbnailCalls[thumbnail] = call
+	}
+	thumbnailMutex.Unlock()
+	if ok {
+		<-call.done
+		return call.path, call.err
+	}
+
+	defer func() {
+		thumbnailMutex.Lock()
+		delete(thumbnailCalls, thumbnail)
+		thumbnailMutex.Unlock()
+		close(call.done)
+	}()
+	thumbnailJobs <- struct{}{}
+	defer func() { <-thumbnailJobs }()
+
+	call.path, call.err = generateThumbnail(thumbnail, filePath, contentType)
+	return call.path, call.err
+}
+
+// generateThumbnail writes the thumbnail of an uploaded file to thumbnail
+func generateThumbnail(thumbnail, filePath, contentType string) (string, error) {
+
+	if err := os.MkdirAll(filepath.Dir(thumbnail), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(thumbnail), ".thumbnail-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = preview.Generate(tmp, filePath, contentType)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	return thumbnail, os.Rename(tmp.Name(), thumbnail)
+}
+
+// HandleGetThumbnail returns a JPEG thumbnail of an uploaded image or of the
+// first page of a PDF. Other materials, or files whose thumbnail cannot be
+// generated, receive placeholder metadata as JSON.
+func HandleGetThumbnail(c *gin.Context) {
+	access, ok := materialAccess(c, c.Param("id"), models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	tree, err := storage.LoadMaterials(access.OwnerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar materiais"})
+		return
+	}
+	node := storage.FindNodeByID(tree.Root, c.Param("id"))
+	if node == nil || node.Type != "material" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material não encontrado"})
+		return
+	}
+
+	c.Header("Cache-Control", thumbnailCacheControl)
+	if !node.IsFile {
+		c.JSON(http.StatusOK, gin.H{"kind": "link", "url": node.URL})
+		return
+	}
+
+	// Validate file path to prevent path traversal
+	cleanPath := filepath.Clean(node.FilePath)
+	if !strings.HasPrefix(cleanPath, storage.UserUploadsDir(access.OwnerID)+string(filepath.Separator)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso não autorizado"})
+		return
+	}
+	if _, err := os.Stat(cleanPath); os.IsNotExist(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Arquivo não encontrado no servidor"})
+		return
+	}
+
+	contentType := servedContentType(node, cleanPath)
+	kind := preview.Kind(contentType)
+	if kind == preview.KindImage || kind == preview.KindPDF {
+		etag := `"` + filepath.Base(cleanPath) + `"`
+		if c.GetHeader("If-None-Match") == etag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		thumbnail, err := cachedThumbnail(access.OwnerID, cleanPath, contentType)
+		if err == nil {
+			c.Header("ETag", etag)
+			c.Header("Content-Type", "image/jpeg")
+			c.Header("X-Content-Type-Options", "nosniff")
+			c.File(thumbnail)
+			return
+		}
+		if !errors.Is(err, preview.ErrUnsupported) {
+			log.Printf("WARNING: Could not generate thumbnail of material %s: %v", node.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, thumbnailPlaceholder(node, kind, contentType))
+}
+
+// thumbnailPlaceholder describes a material without a thumbnail so the
+// client can show an icon
+func thumbnailPlaceholder(node *models.MaterialNode, kind, contentType string) gin.H {
+	return gin.H{
+		"kind":        kind,
+		"contentType": contentType,
+		"fileName":    node.FileName,
+		"fileSize":    node.FileSize,
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondTrashError writes the response for a failed trash operation
+func respondTrashError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrTrashItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, storage.ErrRestoreConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar lixeira"})
+	}
+}
+
+// HandleListTrash returns the user's deleted items, oldest first
+func HandleListTrash(c *gin.Context) {
+	data, err := storage.LoadTrash(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao carregar lixeira"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"items":         data.Items,
+		"retentionDays": int(storage.TrashRetention().Hours() / 24),
+	})
+}
+
+// HandleRestoreTrashItem puts a deleted item back where it was
+func HandleRestoreTrashItem(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	item, err := storage.RestoreFromTrash(userID, c.Param("id"))
+	if err != nil {
+		respondTrashError(c, err)
+		return
+	}
+	if item.Kind == models.TrashMaterial {
+		if err := queueRestoredTexts(userID, item.Node); err != nil {
+			log.Printf("ERROR: Could not queue text extraction of restored materials of user %d: %v", userID, err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "restaurado", "item": item})
+}
+
+// queueRestoredTexts queues the extraction of the restored files whose text
+// is missing or was still pending when they were deleted
+func queueRestoredTexts(userID int64, node *models.MaterialNode) error {
+	texts, err := storage.LoadMaterialTexts(userID)
+	if err != nil {
+		return err
+	}
+	var walk func(node *models.MaterialNode) error
+	walk = func(node *models.MaterialNode) error {
+		if text, ok := texts.Texts[node.ID]; node.IsFile && (!ok || text.Status == models.TextPending) {
+			if err := queueTextExtraction(userID, node.ID); err != nil {
+				return err
+			}
+		}
+		for _, child := range node.Children {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(node)
+}
+
+// HandlePurgeTrashItem permanently deletes an item of the trash
+func HandlePurgeTrashItem(c *gin.Context) {
+	if err := storage.PurgeTrashItem(middleware.GetUserID(c), c.Param("id")); err != nil {
+		respondTrashError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "excluído permanentemente"})
+}
+
+// HandleEmptyTrash permanently deletes every item of the trash
+func HandleEmptyTrash(c *gin.Context) {
+	if err := storage.EmptyTrash(middleware.GetUserID(c)); err != nil {
+		respondTrashError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "lixeira esvaziada"})
+}
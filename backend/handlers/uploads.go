@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chunkContentType is the content type of the chunks of a resumable upload,
+// as in the tus protocol
+const chunkContentType = "application/offset+octet-stream"
+
+// setUploadHeaders reports the progress of an upload session in headers
+func setUploadHeaders(c *gin.Context, session models.UploadSession) {
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(session.Size, 10))
+	c.Header("Cache-Control", "no-store")
+}
+
+// respondUploadError writes the response for an error returned by the
+// upload session storage
+func respondUploadError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrUploadSessionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Envio não encontrado ou expirado"})
+	case errors.Is(err, storage.ErrUploadOffsetMismatch),
+		errors.Is(err, storage.ErrUploadBusy),
+		errors.Is(err, storage.ErrUploadIncomplete):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, storage.ErrTooManyUploads):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, storage.ErrUploadQuotaExceeded):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Cota de armazenamento excedida"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar arquivo"})
+	}
+}
+
+// HandleCreateUpload starts a resumable upload for a file of the declared size
+func HandleCreateUpload(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	var req models.CreateUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	if !isAllowedExtension(req.FileName) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": "Tipo de arquivo não permitido. Tipos permitidos: PDF, DOC, DOCX, PPT, PPTX, XLS, XLSX, TXT, JPG, JPEG, PNG, GIF, MP4, MP3",
+		})
+		return
+	}
+	if req.FileSize <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tamanho de arquivo inválido"})
+		return
+	}
+	if req.FileSize > storage.MaxResumableFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Arquivo muito grande. Limite: 2GB"})
+		return
+	}
+
+	session, err := storage.CreateUploadSession(userID, sanitizeFileName(req.FileName), req.FileSize)
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	c.Header("Location", "/api/materials/uploads/"+session.ID)
+	setUploadHeaders(c, session)
+	c.JSON(http.StatusCreated, session)
+}
+
+// HandleGetUpload returns the progress of an upload session. HEAD requests
+// only receive the Upload-Offset and Upload-Length headers.
+func HandleGetUpload(c *gin.Context) {
+	session, err := storage.GetUploadSession(middleware.GetUserID(c), c.Param("id"))
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	setUploadHeaders(c, session)
+	if c.Request.Method == http.MethodHead {
+		c.Status(http.StatusOK)
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// HandlePatchUpload appends a chunk to an upload session. The Upload-Offset
+// header must match the bytes already received.
+func HandlePatchUpload(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id := c.Param("id")
+
+	if c.ContentType() != chunkContentType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Envie as partes como " + chunkContentType})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cabeçalho Upload-Offset inválido"})
+		return
+	}
+
+	session, err := storage.GetUploadSession(userID, id)
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+	if c.Request.ContentLength > session.Size-offset {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "A parte ultrapassa o tamanho declarado do arquivo"})
+		return
+	}
+
+	session, err = storage.WriteUploadChunk(userID, id, offset, c.Request.Body)
+	if session.ID != "" {
+		setUploadHeaders(c, session)
+	}
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// HandleFinishUpload turns a completed upload session into a material
+func HandleFinishUpload(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	var req models.FinishUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	var material *models.MaterialNode
+	var tree *models.MaterialsTree
+	err := storage.FinishUploadSession(userID, c.Param("id"), func(session models.UploadSession) error {
+		var err error
+		tree, err = storage.UpdateMaterials(userID, revision, func(tree *models.MaterialsTree) error {
+			filePath, contentType, err := verifiedUpload(userID, session.FilePath, session.FileName)
+			if err != nil {
+				return err
+			}
+			material, err = storage.AddMaterialWithFile(tree, req.Name, req.ParentID, req.MaterialType, "", filePath, session.FileName, session.Size, req.Description, true)
+			if err != nil {
+				return treeError(err)
+			}
+			material.ContentType = contentType
+			return nil
+		})
+		return err
+	})
+	switch {
+	case errors.Is(err, storage.ErrRevisionConflict), errors.As(err, new(validationError)):
+		respondMaterialsError(c, err)
+		return
+	case err != nil:
+		respondUploadError(c, err)
+		return
+	}
+
+	if err := queueTextExtraction(userID, material.ID); err != nil {
+		log.Printf("ERROR: Could not queue text extraction of material %s: %v", material.ID, err)
+	}
+
+	setRevisionHeader(c, tree.Revision)
+	c.JSON(http.StatusCreated, material)
+}
+
+// HandleDeleteUpload cancels an upload session and discards the received bytes
+func HandleDeleteUpload(c *gin.Context) {
+	if err := storage.DeleteUploadSession(middleware.GetUserID(c), c.Param("id")); err != nil {
+		respondUploadError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "cancelado"})
+}
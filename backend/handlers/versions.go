@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"studybuddy/middleware"
+	"studybuddy/models"
+	"studybuddy/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseVersion reads the :version parameter of a file version route
+func parseVersion(c *gin.Context) (int, bool) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Versão inválida"})
+		return 0, false
+	}
+	return version, true
+}
+
+// releaseVersions removes the files of versions dropped by
+// storage.AddFileVersion that no other material references
+func releaseVersions(ownerID int64, paths []string) {
+	if err := storage.ReleaseUploads(ownerID, paths); err != nil {
+		log.Printf("ERROR: Could not release old file versions of user %d: %v", ownerID, err)
+	}
+}
+
+// HandleListVersions returns the current and previous files of a material
+func HandleListVersions(c *gin.Context) {
+	access, ok := materialAccess(c, c.Param("id"), models.RoleViewer)
+	if !ok {
+		return
+	}
+	node, ok := findFileMaterial(c, access.OwnerID, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	versions := node.Versions
+	if versions == nil {
+		versions = []models.FileVersion{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"current": models.FileVersion{
+			Version:     max(node.Version, 1),
+			FilePath:    node.FilePath,
+			FileName:    node.FileName,
+			FileSize:    node.FileSize,
+			ContentType: node.ContentType,
+		},
+		"versions": versions,
+	})
+}
+
+// HandleAddVersion replaces the file of a material with a new upload,
+// keeping the current file as a previous version. The file comes from
+// /api/materials/upload (filePath and fileName) or from a completed
+// resumable upload (uploadId).
+func HandleAddVersion(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	nodeID := c.Param("id")
+
+	var req models.NewVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+	if req.UploadID == "" && (req.FilePath == "" || req.FileName == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe o arquivo enviado (filePath e fileName) ou o envio retomável (uploadId)"})
+		return
+	}
+
+	access, ok := materialAccess(c, nodeID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	// replace runs inside UpdateMaterials so the upload cannot be collected
+	// before the tree references it
+	var material *models.MaterialNode
+	var dropped []string
+	replace := func(tree *models.MaterialsTree, filePath, fileName string) error {
+		material = storage.FindNodeByID(tree.Root, nodeID)
+		if material == nil {
+			return treeError(errors.New("nó não encontrado"))
+		}
+		cleanPath, contentType, err := adoptUpload(userID, access, filePath, fileName)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(cleanPath)
+		if err != nil {
+			return err
+		}
+		dropped, err = storage.AddFileVersion(material, cleanPath, fileName, info.Size(), contentType)
+		return treeError(err)
+	}
+
+	var tree *models.MaterialsTree
+	var err error
+	if req.UploadID != "" {
+		err = storage.FinishUploadSession(userID, req.UploadID, func(session models.UploadSession) error {
+			var err error
+			tree, err = storage.UpdateMaterials(access.OwnerID, revision, func(tree *models.MaterialsTree) error {
+				return replace(tree, session.FilePath, session.FileName)
+			})
+			return err
+		})
+	} else {
+		tree, err = storage.UpdateMaterials(access.OwnerID, revision, func(tree *models.MaterialsTree) error {
+			return replace(tree, req.FilePath, sanitizeFileName(req.FileName))
+		})
+	}
+	switch {
+	case errors.Is(err, storage.ErrRevisionConflict), errors.As(err, new(validationError)):
+		respondAccessMaterialsError(c, access, err)
+		return
+	case err != nil && req.UploadID != "":
+		respondUploadError(c, err)
+		return
+	case err != nil:
+		respondAccessMaterialsError(c, access, err)
+		return
+	}
+
+	releaseVersions(access.OwnerID, dropped)
+	if err := queueTextExtraction(access.OwnerID, material.ID); err != nil {
+		log.Printf("ERROR: Could not queue text extraction of material %s: %v", material.ID, err)
+	}
+	if isShared(c, access) {
+		storage.StripShares(material)
+	}
+
+	setRevisionHeader(c, tree.Revision)
+	c.JSON(http.StatusCreated, material)
+}
+
+// HandleRestoreVersion makes a previous file of a material current again.
+// The restored file becomes a new version, so no version is lost.
+func HandleRestoreVersion(c *gin.Context) {
+	nodeID := c.Param("id")
+	version, ok := parseVersion(c)
+	if !ok {
+		return
+	}
+
+	access, ok := materialAccess(c, nodeID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	revision, ok := ifMatchRevision(c)
+	if !ok {
+		return
+	}
+
+	var material *models.MaterialNode
+	var dropped []string
+	tree, err := storage.UpdateMaterials(access.OwnerID, revision, func(tree *models.MaterialsTree) error {
+		material = storage.FindNodeByID(tree.Root, nodeID)
+		if material == nil {
+			return treeError(errors.New("nó não encontrado"))
+		}
+		previous := storage.FindFileVersion(material, version)
+		if previous == nil {
+			return treeError(errors.New("versão não encontrada"))
+		}
+		restored := *previous
+		var err error
+		dropped, err = storage.AddFileVersion(material, restored.FilePath, restored.FileName, restored.FileSize, restored.ContentType)
+		return treeError(err)
+	})
+	if err != nil {
+		respondAccessMaterialsError(c, access, err)
+		return
+	}
+
+	releaseVersions(access.OwnerID, dropped)
+	if err := queueTextExtraction(access.OwnerID, material.ID); err != nil {
+		log.Printf("ERROR: Could not queue text extraction of material %s: %v", material.ID, err)
+	}
+	if isShared(c, access) {
+		storage.StripShares(material)
+	}
+
+	setRevisionHeader(c, tree.Revision)
+	c.JSON(http.StatusOK, material)
+}
+
+// HandleDownloadVersion downloads a previous file of a material
+func HandleDownloadVersion(c *gin.Context) {
+	version, ok := parseVersion(c)
+	if !ok {
+		return
+	}
+	access, ok := materialAccess(c, c.Param("id"), models.RoleViewer)
+	if !ok {
+		return
+	}
+	serveMaterial(c, access.OwnerID, c.Param("id"), version, "attachment")
+}
@@ -0,0 +1,293 @@
+// Package ical encodes and parses the subset of iCalendar (RFC 5545) used
+// to share events and reminders with external calendar apps.
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Layouts of DATE and DATE-TIME values
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405"
+)
+
+// maxLineLength is the line length after which content lines are folded
+const maxLineLength = 75
+
+// ErrInvalidCalendar is returned when the input is not an iCalendar object
+var ErrInvalidCalendar = errors.New("arquivo iCalendar inválido")
+
+// Event is a VEVENT. Times without an explicit time zone are floating and
+// interpreted in the server's local time.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	AllDay      bool
+	RRule       string      // Recurrence rule value, empty for a single event
+	ExDates     []time.Time // Skipped occurrences of a recurring event
+}
+
+// Todo is a VTODO
+type Todo struct {
+	UID       string
+	Summary   string
+	Due       time.Time
+	AllDay    bool
+	Completed bool
+	Priority  int // 1 (highest) to 9 (lowest), 0 for undefined
+	RRule     string
+	ExDates   []time.Time
+}
+
+// Encode writes a VCALENDAR with the given events and todos. stamp is used
+// as DTSTAMP for every component.
+func Encode(w io.Writer, name string, events []Event, todos []Todo, stamp time.Time) error {
+	b := &builder{w: bufio.NewWriter(w)}
+	b.line("BEGIN:VCALENDAR")
+	b.line("VERSION:2.0")
+	b.line("PRODID:-//StudyBuddy//StudyBuddy//PT")
+	b.line("CALSCALE:GREGORIAN")
+	b.line("X-WR-CALNAME:" + escapeText(name))
+
+	dtstamp := "DTSTAMP:" + stamp.UTC().Format(dateTimeLayout) + "Z"
+	for _, event := range events {
+		b.line("BEGIN:VEVENT")
+		b.line("UID:" + escapeText(event.UID))
+		b.line(dtstamp)
+		b.line(formatTime("DTSTART", event.Start, event.AllDay))
+		b.recurrence(event.RRule, event.ExDates, event.AllDay)
+		b.line("SUMMARY:" + escapeText(event.Summary))
+		if event.Description != "" {
+			b.line("DESCRIPTION:" + escapeText(event.Description))
+		}
+		b.line("END:VEVENT")
+	}
+	for _, todo := range todos {
+		b.line("BEGIN:VTODO")
+		b.line("UID:" + escapeText(todo.UID))
+		b.line(dtstamp)
+		b.line(formatTime("DUE", todo.Due, todo.AllDay))
+		b.recurrence(todo.RRule, todo.ExDates, todo.AllDay)
+		b.line("SUMMARY:" + escapeText(todo.Summary))
+		if todo.Priority > 0 {
+			b.line(fmt.Sprintf("PRIORITY:%d", todo.Priority))
+		}
+		if todo.Completed {
+			b.line("STATUS:COMPLETED")
+		} else {
+			b.line("STATUS:NEEDS-ACTION")
+		}
+		b.line("END:VTODO")
+	}
+
+	b.line("END:VCALENDAR")
+	if b.err != nil {
+		return b.err
+	}
+	return b.w.Flush()
+}
+
+// ParseEvents reads the VEVENTs of an iCalendar object, with their
+// recurrence rule and skipped occurrences. Events without DTSTART are
+// skipped.
+func ParseEvents(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, ErrInvalidCalendar
+	}
+
+	var events []Event
+	var current *Event
+	hasStart := false
+	for _, line := range lines {
+		name, params, value := parseLine(line)
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			current = &Event{}
+			hasStart = false
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if current != nil && hasStart {
+				events = append(events, *current)
+			}
+			current = nil
+		case current == nil:
+			continue
+		case name == "UID":
+			current.UID = unescapeText(value)
+		case name == "SUMMARY":
+			current.Summary = unescapeText(value)
+		case name == "DESCRIPTION":
+			current.Description = unescapeText(value)
+		case name == "DTSTART":
+			start, allDay, err := parseTime(value, params)
+			if err == nil {
+				current.Start, current.AllDay, hasStart = start, allDay, true
+			}
+		case name == "RRULE":
+			current.RRule = value
+		case name == "EXDATE":
+			// A property may list several comma separated dates
+			for _, item := range strings.Split(value, ",") {
+				if exdate, _, err := parseTime(item, params); err == nil {
+					current.ExDates = append(current.ExDates, exdate)
+				}
+			}
+		}
+	}
+	return events, nil
+}
+
+// builder writes folded content lines, keeping the first error
+type builder struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line writes a content line folded at maxLineLength octets without
+// splitting UTF-8 sequences
+func (b *builder) line(s string) {
+	if b.err != nil {
+		return
+	}
+	for len(s) > maxLineLength {
+		cut := maxLineLength
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		if _, b.err = b.w.WriteString(s[:cut] + "\r\n "); b.err != nil {
+			return
+		}
+		s = s[cut:]
+	}
+	_, b.err = b.w.WriteString(s + "\r\n")
+}
+
+// recurrence writes the RRULE and EXDATE properties of a recurring component
+func (b *builder) recurrence(rrule string, exdates []time.Time, allDay bool) {
+	if rrule == "" {
+		return
+	}
+	b.line("RRULE:" + rrule)
+	for _, exdate := range exdates {
+		b.line(formatTime("EXDATE", exdate, allDay))
+	}
+}
+
+// formatTime formats a DATE or floating DATE-TIME property
+func formatTime(name string, t time.Time, allDay bool) string {
+	if allDay {
+		return name + ";VALUE=DATE:" + t.Format(dateLayout)
+	}
+	return name + ":" + t.Format(dateTimeLayout)
+}
+
+// parseTime parses a DATE or DATE-TIME value. UTC and TZID times are
+// converted to the server's local time.
+func parseTime(value string, params map[string]string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == len(dateLayout) {
+		t, err := time.ParseInLocation(dateLayout, value, time.Local)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(dateTimeLayout, strings.TrimSuffix(value, "Z"))
+		return t.Local(), false, err
+	}
+
+	loc := time.Local
+	if tzid := params["TZID"]; tzid != "" {
+		if tz, err := time.LoadLocation(tzid); err == nil {
+			loc = tz
+		}
+	}
+	t, err := time.ParseInLocation(dateTimeLayout, value, loc)
+	return t.Local(), false, err
+}
+
+// unfold reads content lines, joining folded continuation lines
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseLine splits a content line into its upper-cased name, parameters
+// and value
+func parseLine(line string) (string, map[string]string, string) {
+	params := make(map[string]string)
+
+	// The value starts at the first colon outside a quoted parameter value
+	inQuotes := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon == -1 {
+		return strings.ToUpper(line), params, ""
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	for _, param := range parts[1:] {
+		if key, value, ok := strings.Cut(param, "="); ok {
+			params[strings.ToUpper(key)] = strings.Trim(value, "\"")
+		}
+	}
+	return strings.ToUpper(parts[0]), params, line[colon+1:]
+}
+
+// escapeText escapes a TEXT value
+func escapeText(s string) string {
+	return strings.NewReplacer(
+		"\\", "\\\\",
+		";", "\\;",
+		",", "\\,",
+		"\r\n", "\\n",
+		"\n", "\\n",
+	).Replace(s)
+}
+
+// unescapeText reverses escapeText
+func unescapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
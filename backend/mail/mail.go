@@ -0,0 +1,109 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Message represents an email to be delivered
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email messages
+type Sender interface {
+	Send(msg Message) error
+}
+
+// LogSender prints messages to the server log instead of sending them.
+// Useful for local development.
+type LogSender struct{}
+
+// Send writes the message to the log
+func (LogSender) Send(msg Message) error {
+	log.Printf("MAIL to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileSender writes each message as an .eml file inside Dir
+type FileSender struct {
+	Dir string
+}
+
+// Send writes the message to a new file in the outbox directory
+func (s FileSender) Send(msg Message) error {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), sanitizeAddress(msg.To))
+	content := fmt.Sprintf("To: %s\r\nSubject: %s\r\nDate: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		msg.To, msg.Subject, time.Now().Format(time.RFC1123Z), msg.Body)
+	return os.WriteFile(filepath.Join(s.Dir, name), []byte(content), 0644)
+}
+
+// SMTPSender delivers messages through an SMTP server, upgrading the
+// connection with STARTTLS when the server supports it
+type SMTPSender struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers the message to the SMTP server
+func (s SMTPSender) Send(msg Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	content := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		s.From, msg.To, mime.QEncoding.Encode("UTF-8", msg.Subject), time.Now().Format(time.RFC1123Z), msg.Body)
+	return smtp.SendMail(net.JoinHostPort(s.Host, s.Port), auth, s.From, []string{msg.To}, []byte(content))
+}
+
+// sanitizeAddress turns an email address into a safe file name fragment
+func sanitizeAddress(address string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, address)
+}
+
+// NewSenderFromEnv returns an SMTPSender when SMTP_HOST is set, a
+// FileSender when MAIL_OUTBOX_DIR is set and a LogSender otherwise
+func NewSenderFromEnv() Sender {
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		sender := SMTPSender{
+			Host:     host,
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		}
+		if sender.Port == "" {
+			sender.Port = "587"
+		}
+		if sender.From == "" {
+			sender.From = sender.Username
+		}
+		return sender
+	}
+	if dir := os.Getenv("MAIL_OUTBOX_DIR"); dir != "" {
+		return FileSender{Dir: dir}
+	}
+	return LogSender{}
+}
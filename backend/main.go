@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"studybuddy/extract"
 	"studybuddy/handlers"
+	"studybuddy/mail"
 	"studybuddy/middleware"
+	"studybuddy/notify"
 	"studybuddy/storage"
 	"time"
 
@@ -12,17 +17,68 @@ import (
 )
 
 func main() {
-	// Load existing users from file
+	migrateOwner := flag.String("migrate-owner", "", "assign the legacy shared data.json/materials.json to the user with this email and exit")
+	importJSON := flag.String("import-json", "", "import users.json and the per-user data/materials JSON files from this directory into the configured backend and exit")
+	flag.Parse()
+
+	// Select the storage backend (STORAGE_BACKEND=json|sqlite)
+	backend, err := storage.NewBackendFromEnv()
+	if err != nil {
+		log.Fatalf("Could not open storage: %v", err)
+	}
+	defer backend.Close()
+	storage.SetBackend(backend)
+
+	// Import existing JSON files into the configured backend
+	if *importJSON != "" {
+		if err := storage.ImportJSON(*importJSON); err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		log.Println("Import finished")
+		return
+	}
+
+	// Load existing users from the storage backend
 	storage.LoadUsers()
 
+	// One-time migration of the data shared by all accounts
+	if *migrateOwner != "" {
+		if err := storage.MigrateLegacyData(*migrateOwner); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		log.Println("Migration finished")
+		return
+	}
+
+	sender := mail.NewSenderFromEnv()
+	handlers.SetMailSender(sender)
+
+	// Background delivery of reminder and event notifications
+	scheduler := notify.NewSchedulerFromEnv(sender, handlers.AppBaseURL())
+	handlers.SetVAPIDPublicKey(scheduler.PushPublicKey())
+	go scheduler.Run(context.Background())
+
+	// Purge deleted items kept longer than the trash retention period
+	storage.PurgeExpiredTrash()
+	go storage.RunTrashCollector(context.Background(), time.Hour)
+
+	// Move uploads to the content-addressed store and remove orphaned files
+	storage.CollectUploads()
+	go storage.RunUploadCollector(context.Background(), time.Hour)
+
+	// Background extraction of the text of uploaded materials
+	extractor := extract.NewPipelineFromEnv()
+	handlers.SetTextExtractor(extractor)
+	go extractor.Run(context.Background())
+
 	r := gin.Default()
 
 	// Configure CORS
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "If-Match", "If-None-Match", "Range", "If-Range", "Upload-Offset"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Range", "Accept-Ranges", "ETag", "Location", "Upload-Offset", "Upload-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -33,12 +89,33 @@ func main() {
 	r.StaticFile("/login", "./static/login.html")
 	r.StaticFile("/register", "./static/register.html")
 	r.StaticFile("/forgot-password", "./static/forgot-password.html")
+	r.StaticFile("/reset-password", "./static/reset-password.html")
 
 	// Public authentication routes
 	auth := r.Group("/auth")
 	{
 		auth.POST("/login", handlers.HandleLogin)
 		auth.POST("/register", handlers.HandleRegister)
+		auth.POST("/forgot-password", handlers.HandleForgotPassword)
+		auth.POST("/reset-password", handlers.HandleResetPassword)
+		auth.POST("/refresh", handlers.HandleRefresh)
+		auth.POST("/logout", handlers.HandleLogout)
+	}
+
+	// Public calendar feed, identified by the secret token in the URL
+	r.GET("/calendar/:token", handlers.HandleCalendarFeed)
+
+	// Uploaded files, identified by a short-lived signed token
+	r.GET("/files/:token", handlers.HandleSignedFile)
+	r.HEAD("/files/:token", handlers.HandleSignedFile)
+
+	// Public share links, identified by the secret token in the URL
+	r.GET("/s/:token", handlers.HandleShareLinkPage)
+	public := r.Group("/api/public/links")
+	{
+		public.GET("/:token", handlers.HandleGetShareLink)
+		public.POST("/:token/open", handlers.HandleOpenShareLink)
+		public.POST("/:token/files/:id", handlers.HandleShareLinkFile)
 	}
 
 	api := r.Group("/api")
@@ -46,24 +123,129 @@ func main() {
 	{
 		api.GET("/data", handlers.HandleGetData)
 		api.POST("/data", handlers.HandleSaveData)
+
+		// Granular resources inside the application data
+		api.GET("/notes", handlers.HandleListNotes)
+		api.POST("/notes", handlers.HandleCreateNote)
+		api.GET("/notes/:id", handlers.HandleGetNote)
+		api.PATCH("/notes/:id", handlers.HandlePatchNote)
+		api.DELETE("/notes/:id", handlers.HandleDeleteNote)
+		api.GET("/notes/:id/history", handlers.HandleListNoteHistory)
+		api.GET("/notes/:id/diff", handlers.HandleDiffNote)
+		api.POST("/notes/:id/history/:revision/restore", handlers.HandleRestoreNoteRevision)
+
+		api.GET("/reminders", handlers.HandleListReminders)
+		api.POST("/reminders", handlers.HandleCreateReminder)
+		api.GET("/reminders/:id", handlers.HandleGetReminder)
+		api.PATCH("/reminders/:id", handlers.HandlePatchReminder)
+		api.DELETE("/reminders/:id", handlers.HandleDeleteReminder)
+		api.PUT("/reminders/:id/occurrences/:date", handlers.HandleSetReminderOccurrence)
+		api.DELETE("/reminders/:id/occurrences/:date", handlers.HandleSkipReminderOccurrence)
+
+		api.GET("/events", handlers.HandleListEvents)
+		api.POST("/events", handlers.HandleCreateEvent)
+		api.GET("/events/:id", handlers.HandleGetEvent)
+		api.PATCH("/events/:id", handlers.HandlePatchEvent)
 		api.DELETE("/events/:id", handlers.HandleDeleteEvent)
+		api.DELETE("/events/:id/occurrences/:date", handlers.HandleSkipEventOccurrence)
+		api.GET("/occurrences", handlers.HandleListOccurrences)
+
+		api.GET("/subjects", handlers.HandleListSubjects)
+		api.POST("/subjects", handlers.HandleCreateSubject)
+		api.PATCH("/subjects/:name", handlers.HandleRenameSubject)
+		api.DELETE("/subjects/:name", handlers.HandleDeleteSubject)
+
+		// Flashcards routes
+		api.GET("/flashcards/decks", handlers.HandleListDecks)
+		api.POST("/flashcards/decks", handlers.HandleCreateDeck)
+		api.DELETE("/flashcards/decks/:id", handlers.HandleDeleteDeck)
+		api.GET("/flashcards/cards", handlers.HandleListCards)
+		api.POST("/flashcards/cards", handlers.HandleCreateCard)
+		api.POST("/flashcards/cards/from-note/:noteId", handlers.HandleCreateCardFromNote)
+		api.PATCH("/flashcards/cards/:id", handlers.HandlePatchCard)
+		api.DELETE("/flashcards/cards/:id", handlers.HandleDeleteCard)
+		api.POST("/flashcards/cards/:id/review", handlers.HandleReviewCard)
+		api.GET("/flashcards/due", handlers.HandleDueCards)
+
+		// Focus session routes
+		api.GET("/focus/sessions", handlers.HandleListFocusSessions)
+		api.POST("/focus/sessions", handlers.HandleStartFocusSession)
+		api.GET("/focus/current", handlers.HandleCurrentFocusSession)
+		api.POST("/focus/sessions/:id/pause", handlers.HandlePauseFocusSession)
+		api.POST("/focus/sessions/:id/resume", handlers.HandleResumeFocusSession)
+		api.POST("/focus/sessions/:id/finish", handlers.HandleFinishFocusSession)
+
+		// Calendar routes
+		api.GET("/calendar/feed", handlers.HandleGetCalendarFeed)
+		api.POST("/calendar/feed", handlers.HandleCreateCalendarFeed)
+		api.DELETE("/calendar/feed", handlers.HandleDeleteCalendarFeed)
+		api.POST("/calendar/import", handlers.HandleImportCalendar)
+
+		// Notification routes
+		api.GET("/notifications/settings", handlers.HandleGetNotificationSettings)
+		api.PUT("/notifications/settings", handlers.HandleUpdateNotificationSettings)
+		api.GET("/notifications/push/key", handlers.HandleGetPushKey)
+		api.POST("/notifications/push/subscriptions", handlers.HandleSubscribePush)
+		api.DELETE("/notifications/push/subscriptions", handlers.HandleUnsubscribePush)
+
+		// Trash
+		api.GET("/trash", handlers.HandleListTrash)
+		api.POST("/trash/:id/restore", handlers.HandleRestoreTrashItem)
+		api.DELETE("/trash/:id", handlers.HandlePurgeTrashItem)
+		api.DELETE("/trash", handlers.HandleEmptyTrash)
+
+		// Search
+		api.GET("/search", handlers.HandleSearch)
+
+		// Statistics
+		api.GET("/stats", handlers.HandleGetStats)
+
+		// Account routes
+		api.GET("/account", handlers.HandleGetAccount)
+		api.PUT("/account", handlers.HandleUpdateAccount)
+		api.PUT("/account/password", handlers.HandleChangePassword)
+		api.PUT("/account/email", handlers.HandleChangeEmail)
+		api.DELETE("/account", handlers.HandleDeleteAccount)
+		api.GET("/account/sessions", handlers.HandleListSessions)
+		api.DELETE("/account/sessions", handlers.HandleRevokeAllSessions)
+		api.DELETE("/account/sessions/:id", handlers.HandleRevokeSession)
 
 		// Materials routes
 		api.GET("/materials", handlers.HandleGetMaterials)
+		api.GET("/materials/shared", handlers.HandleGetSharedMaterials)
 		api.GET("/materials/:id", handlers.HandleGetMaterialNode)
 		api.POST("/materials/folder", handlers.HandleCreateFolder)
 		api.POST("/materials/material", handlers.HandleCreateMaterial)
 		api.POST("/materials/upload", handlers.HandleUploadFile)
+		api.POST("/materials/uploads", handlers.HandleCreateUpload)
+		api.GET("/materials/uploads/:id", handlers.HandleGetUpload)
+		api.HEAD("/materials/uploads/:id", handlers.HandleGetUpload)
+		api.PATCH("/materials/uploads/:id", handlers.HandlePatchUpload)
+		api.POST("/materials/uploads/:id/finish", handlers.HandleFinishUpload)
+		api.DELETE("/materials/uploads/:id", handlers.HandleDeleteUpload)
 		api.GET("/materials/download/:id", handlers.HandleDownloadFile)
 		api.GET("/materials/view/:id", handlers.HandleViewFile)
+		api.POST("/materials/:id/url", handlers.HandleCreateFileURL)
+		api.GET("/materials/:id/position", handlers.HandleGetMediaPosition)
+		api.PUT("/materials/:id/position", handlers.HandleSaveMediaPosition)
+		api.GET("/materials/:id/thumbnail", handlers.HandleGetThumbnail)
+		api.GET("/materials/:id/shares", handlers.HandleListShares)
+		api.POST("/materials/:id/shares", handlers.HandleShareNode)
+		api.DELETE("/materials/:id/shares/:userId", handlers.HandleRevokeShare)
+		api.POST("/materials/:id/links", handlers.HandleCreateShareLink)
+		api.GET("/links", handlers.HandleListShareLinks)
+		api.DELETE("/links/:id", handlers.HandleRevokeShareLink)
+		api.GET("/materials/:id/versions", handlers.HandleListVersions)
+		api.POST("/materials/:id/versions", handlers.HandleAddVersion)
+		api.POST("/materials/:id/versions/:version/restore", handlers.HandleRestoreVersion)
+		api.GET("/materials/:id/versions/:version/download", handlers.HandleDownloadVersion)
 		api.PUT("/materials/:id", handlers.HandleUpdateNode)
 		api.DELETE("/materials/:id", handlers.HandleDeleteNode)
 		api.PUT("/materials/:id/move", handlers.HandleMoveNode)
+		api.GET("/materials/:id/text", handlers.HandleGetMaterialText)
+		api.POST("/materials/:id/text", handlers.HandleExtractMaterialText)
 	}
 
-	// Serve uploads directory for static file access (optional)
-	r.Static("/uploads", "./storage/uploads")
-
 	log.Println("Server starting on port 8080...")
 	r.Run(":8080")
 }
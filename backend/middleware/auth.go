@@ -3,6 +3,7 @@ package middleware
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"studybuddy/storage"
 
@@ -10,6 +11,12 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
+// Gin context keys set by AuthMiddleware
+const (
+	UserIDKey    = "userID"    // Authenticated user's ID
+	SessionIDKey = "sessionID" // Session the access token belongs to
+)
+
 // AuthMiddleware validates JWT tokens for protected routes
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -25,7 +32,8 @@ func AuthMiddleware() gin.HandlerFunc {
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
 		// Parse the token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		claims := &jwt.RegisteredClaims{}
+		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 			// Validate the signing method
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, fmt.Errorf("método de assinatura inesperado: %v", token.Header["alg"])
@@ -48,6 +56,41 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Resolve the user the token was issued for
+		userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Token inválido",
+			})
+			return
+		}
+		if _, exists := storage.GetUserByID(userID); !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Usuário não encontrado",
+			})
+			return
+		}
+
+		// Reject tokens whose session was logged out or revoked
+		if claims.ID == "" || !storage.IsSessionActive(userID, claims.ID) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Sessão encerrada",
+			})
+			return
+		}
+
+		c.Set(UserIDKey, userID)
+		c.Set(SessionIDKey, claims.ID)
 		c.Next()
 	}
 }
+
+// GetUserID returns the authenticated user's ID stored by AuthMiddleware
+func GetUserID(c *gin.Context) int64 {
+	return c.GetInt64(UserIDKey)
+}
+
+// GetSessionID returns the current session ID stored by AuthMiddleware
+func GetSessionID(c *gin.Context) string {
+	return c.GetString(SessionIDKey)
+}
@@ -8,6 +8,27 @@ type Reminder struct {
 	Time      string `json:"time"`
 	Priority  string `json:"priority"`
 	Completed bool   `json:"completed"`
+	// Recurrence repeats the reminder from Date; nil for a single reminder
+	Recurrence *Recurrence `json:"recurrence,omitempty"`
+	// CompletedDates lists the completed occurrences of a recurring reminder
+	CompletedDates []string `json:"completedDates,omitempty"`
+}
+
+// Recurrence frequencies
+const (
+	RecurDaily   = "daily"
+	RecurWeekly  = "weekly"
+	RecurMonthly = "monthly"
+)
+
+// Recurrence represents the repetition rule of an event or reminder
+type Recurrence struct {
+	Frequency  string   `json:"frequency"`            // daily, weekly or monthly
+	Interval   int      `json:"interval,omitempty"`   // Repeat every N periods, 1 when omitted
+	Weekdays   []int    `json:"weekdays,omitempty"`   // Weekly only: 0 (Sunday) to 6; defaults to the weekday of Date
+	Until      string   `json:"until,omitempty"`      // Last possible date, YYYY-MM-DD
+	Count      int      `json:"count,omitempty"`      // Number of occurrences, including exceptions
+	Exceptions []string `json:"exceptions,omitempty"` // Skipped dates, YYYY-MM-DD
 }
 
 // Note represents a study note
@@ -26,6 +47,22 @@ type Event struct {
 	Date        string `json:"date"`
 	Time        string `json:"time"`
 	Description string `json:"description"`
+	UID         string `json:"uid,omitempty"` // iCalendar UID of imported events
+	// Recurrence repeats the event from Date; nil for a single event
+	Recurrence *Recurrence `json:"recurrence,omitempty"`
+}
+
+// Occurrence represents one expanded date of an event or reminder
+type Occurrence struct {
+	Type        string `json:"type"` // event or reminder
+	ID          int64  `json:"id"`
+	Title       string `json:"title"`
+	Date        string `json:"date"`
+	Time        string `json:"time"`
+	Description string `json:"description,omitempty"`
+	Priority    string `json:"priority,omitempty"`
+	Completed   bool   `json:"completed"`
+	Recurring   bool   `json:"recurring"`
 }
 
 // Material represents a study material
@@ -48,4 +85,47 @@ type AppData struct {
 	Events    []Event        `json:"events"`
 	Materials []Material     `json:"materials"`
 	Folders   []string       `json:"folders"`
+	Revision  int64          `json:"revision"` // Incremented by the server on every write
+}
+
+// NoteRequest represents the body to create or patch a note.
+// Nil fields are left unchanged by PATCH.
+type NoteRequest struct {
+	Title   *string `json:"title"`
+	Content *string `json:"content"`
+	Subject *string `json:"subject"`
+	Date    *string `json:"date"`
+}
+
+// ReminderRequest represents the body to create or patch a reminder.
+// Nil fields are left unchanged by PATCH.
+type ReminderRequest struct {
+	Title     *string `json:"title"`
+	Date      *string `json:"date"`
+	Time      *string `json:"time"`
+	Priority  *string `json:"priority"`
+	Completed *bool   `json:"completed"`
+	// Recurrence sets the repetition rule; an empty frequency removes it
+	Recurrence *Recurrence `json:"recurrence"`
+}
+
+// EventRequest represents the body to create or patch an event.
+// Nil fields are left unchanged by PATCH.
+type EventRequest struct {
+	Title       *string `json:"title"`
+	Date        *string `json:"date"`
+	Time        *string `json:"time"`
+	Description *string `json:"description"`
+	// Recurrence sets the repetition rule; an empty frequency removes it
+	Recurrence *Recurrence `json:"recurrence"`
+}
+
+// OccurrenceRequest represents the completion of a reminder occurrence
+type OccurrenceRequest struct {
+	Completed *bool `json:"completed" binding:"required"`
+}
+
+// SubjectRequest represents the body to create or rename a subject
+type SubjectRequest struct {
+	Name string `json:"name" binding:"required"`
 }
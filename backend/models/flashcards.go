@@ -0,0 +1,59 @@
+package models
+
+// Deck represents a group of flashcards, usually one per subject
+type Deck struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Subject   string `json:"subject"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// Flashcard represents a card and its spaced-repetition schedule
+type Flashcard struct {
+	ID           int64   `json:"id"`
+	DeckID       int64   `json:"deckId"`
+	Front        string  `json:"front"`
+	Back         string  `json:"back"`
+	NoteID       int64   `json:"noteId,omitempty"` // Note the card was derived from
+	EaseFactor   float64 `json:"easeFactor"`
+	Interval     int     `json:"interval"` // Days until the next review
+	Repetitions  int     `json:"repetitions"`
+	DueDate      string  `json:"dueDate"` // YYYY-MM-DD
+	LastReviewed string  `json:"lastReviewed,omitempty"`
+	CreatedAt    string  `json:"createdAt"`
+}
+
+// FlashcardsData represents all decks and cards of a user
+type FlashcardsData struct {
+	Decks []Deck      `json:"decks"`
+	Cards []Flashcard `json:"cards"`
+	// ReviewSeconds accumulates review time per day so partial minutes are
+	// not lost when they are added to AppData.StudyLog
+	ReviewSeconds map[string]int `json:"reviewSeconds"`
+}
+
+// DeckRequest represents the body to create or rename a deck
+type DeckRequest struct {
+	Name    string `json:"name" binding:"required"`
+	Subject string `json:"subject"`
+}
+
+// FlashcardRequest represents the body to create or patch a card.
+// Nil fields are left unchanged by PATCH.
+type FlashcardRequest struct {
+	DeckID *int64  `json:"deckId"`
+	Front  *string `json:"front"`
+	Back   *string `json:"back"`
+}
+
+// FromNoteRequest represents the body to derive a card from a note
+type FromNoteRequest struct {
+	DeckID int64 `json:"deckId"` // Optional; defaults to the deck of the note's subject
+}
+
+// ReviewRequest represents a review of a card
+type ReviewRequest struct {
+	Grade           *int   `json:"grade" binding:"required"` // 0 (forgot) to 5 (perfect)
+	DurationSeconds int    `json:"durationSeconds"`          // Time spent on the card
+	Date            string `json:"date"`                     // Client's local date, YYYY-MM-DD
+}
@@ -0,0 +1,41 @@
+package models
+
+// Focus session states
+const (
+	FocusRunning  = "running"
+	FocusPaused   = "paused"
+	FocusFinished = "finished"
+)
+
+// FocusSegment represents an uninterrupted stretch of a focus session.
+// Timestamps are set by the server in RFC3339.
+type FocusSegment struct {
+	Start string `json:"start"`
+	End   string `json:"end,omitempty"` // Empty while the segment is running
+}
+
+// FocusSession represents a Pomodoro / focus timer session
+type FocusSession struct {
+	ID             int64          `json:"id"`
+	Subject        string         `json:"subject"`
+	PlannedMinutes int            `json:"plannedMinutes"`
+	Status         string         `json:"status"`
+	Segments       []FocusSegment `json:"segments"`
+	StartedAt      string         `json:"startedAt"`
+	FinishedAt     string         `json:"finishedAt,omitempty"`
+	ElapsedSeconds int            `json:"elapsedSeconds"` // Focused time; responses include the running segment
+}
+
+// FocusData represents all focus sessions of a user
+type FocusData struct {
+	Sessions []FocusSession `json:"sessions"`
+	// LoggedSeconds accumulates focused time per day so partial minutes are
+	// not lost when they are added to AppData.StudyLog
+	LoggedSeconds map[string]int `json:"loggedSeconds"`
+}
+
+// FocusStartRequest represents the body to start a focus session
+type FocusStartRequest struct {
+	Subject        string `json:"subject"`
+	PlannedMinutes int    `json:"plannedMinutes"`
+}
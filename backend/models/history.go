@@ -0,0 +1,16 @@
+package models
+
+// NoteRevision is a previous version of a note, recorded when it was changed
+type NoteRevision struct {
+	Revision   int    `json:"revision"`
+	Title      string `json:"title"`
+	Content    string `json:"content"`
+	Subject    string `json:"subject"`
+	ReplacedAt string `json:"replacedAt"` // When this version stopped being current
+}
+
+// NoteHistoryData holds the previous versions of a user's notes by note ID,
+// oldest first
+type NoteHistoryData struct {
+	Notes map[int64][]NoteRevision `json:"notes"`
+}
@@ -12,14 +12,40 @@ type MaterialNode struct {
 	FilePath     string          `json:"filePath,omitempty"`     // Local file path
 	FileName     string          `json:"fileName,omitempty"`     // Original file name
 	FileSize     int64           `json:"fileSize,omitempty"`     // File size in bytes
+	ContentType  string          `json:"contentType,omitempty"`  // MIME type verified from the file content
+	Version      int             `json:"version,omitempty"`      // Number of the current file version; 0 is the first
+	Versions     []FileVersion   `json:"versions,omitempty"`     // Previous files, oldest first
+	Shares       []Share         `json:"shares,omitempty"`       // Users this node and its descendants are shared with
 	Description  string          `json:"description,omitempty"`
 	DateAdded    string          `json:"dateAdded,omitempty"`
 	IsFile       bool            `json:"isFile"` // true = local file, false = external link
 }
 
+// FileVersion is a previous file of a material, kept when a new version
+// is uploaded
+type FileVersion struct {
+	Version     int    `json:"version"`
+	FilePath    string `json:"filePath"`
+	FileName    string `json:"fileName"`
+	FileSize    int64  `json:"fileSize"`
+	ContentType string `json:"contentType,omitempty"`
+	ReplacedAt  string `json:"replacedAt,omitempty"` // When this version stopped being current
+}
+
+// NewVersionRequest represents the request to replace the file of a
+// material. The file is either a simple upload (filePath and fileName) or a
+// completed resumable upload (uploadId).
+type NewVersionRequest struct {
+	FilePath string `json:"filePath"`
+	FileName string `json:"fileName"`
+	FileSize int64  `json:"fileSize"`
+	UploadID string `json:"uploadId"`
+}
+
 // MaterialsTree represents the root structure for materials
 type MaterialsTree struct {
-	Root *MaterialNode `json:"root"`
+	Root     *MaterialNode `json:"root"`
+	Revision int64         `json:"revision"` // Incremented by the server on every write
 }
 
 // CreateFolderRequest represents the request to create a new folder
@@ -57,3 +83,26 @@ type UpdateNodeRequest struct {
 type MoveNodeRequest struct {
 	NewParentID string `json:"newParentId" binding:"required"`
 }
+
+// Text extraction states of an uploaded material
+const (
+	TextPending     = "pending"
+	TextDone        = "done"
+	TextFailed      = "failed"
+	TextUnsupported = "unsupported"
+)
+
+// MaterialText is the plain text extracted from an uploaded material
+type MaterialText struct {
+	Status      string `json:"status"`
+	Text        string `json:"text,omitempty"`
+	Error       string `json:"error,omitempty"`
+	ExtractedAt string `json:"extractedAt,omitempty"`
+}
+
+// MaterialTextsData holds the extraction status of a user's materials by node
+// ID. Texts are stored separately and only set here when they are written.
+type MaterialTextsData struct {
+	Texts    map[string]MaterialText `json:"texts"`
+	Revision int64                   `json:"revision"` // Incremented on every write
+}
@@ -0,0 +1,19 @@
+package models
+
+// MediaPosition is where a user stopped playing a video or audio material
+type MediaPosition struct {
+	Seconds   float64 `json:"seconds"`
+	Duration  float64 `json:"duration,omitempty"`
+	UpdatedAt string  `json:"updatedAt"`
+}
+
+// MediaProgressData holds a user's playback positions by material ID
+type MediaProgressData struct {
+	Positions map[string]MediaPosition `json:"positions"`
+}
+
+// MediaPositionRequest represents the body to save a playback position
+type MediaPositionRequest struct {
+	Seconds  *float64 `json:"seconds" binding:"required"`
+	Duration float64  `json:"duration"`
+}
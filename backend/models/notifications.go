@@ -0,0 +1,54 @@
+package models
+
+// QuietHours represents a daily window without notifications, in HH:MM.
+// The window may cross midnight; empty values disable it.
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// NotificationSettings represents a user's reminder notification preferences
+type NotificationSettings struct {
+	Enabled     bool       `json:"enabled"`
+	Email       bool       `json:"email"`
+	Push        bool       `json:"push"`
+	Webhook     bool       `json:"webhook"`
+	WebhookURL  string     `json:"webhookUrl"`
+	LeadMinutes int        `json:"leadMinutes"` // Notify this many minutes before the item
+	Timezone    string     `json:"timezone"`    // IANA zone of the user's dates, times and quiet hours
+	QuietHours  QuietHours `json:"quietHours"`
+}
+
+// PushKeys represents the encryption keys of a Web Push subscription
+type PushKeys struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
+
+// PushSubscription represents a browser subscribed to Web Push
+type PushSubscription struct {
+	Endpoint  string   `json:"endpoint"`
+	Keys      PushKeys `json:"keys"`
+	CreatedAt string   `json:"createdAt"`
+}
+
+// NotificationDelivery records the delivery of one occurrence on one channel
+type NotificationDelivery struct {
+	Date        string `json:"date"` // Occurrence date, used to prune old records
+	Attempts    int    `json:"attempts"`
+	Delivered   bool   `json:"delivered"`
+	LastAttempt string `json:"lastAttempt"`
+	Error       string `json:"error,omitempty"`
+}
+
+// NotificationsData represents a user's notification settings and state
+type NotificationsData struct {
+	Settings      NotificationSettings            `json:"settings"`
+	Subscriptions []PushSubscription              `json:"subscriptions"`
+	Deliveries    map[string]NotificationDelivery `json:"deliveries"`
+}
+
+// PushUnsubscribeRequest represents the body to remove a push subscription
+type PushUnsubscribeRequest struct {
+	Endpoint string `json:"endpoint" binding:"required"`
+}
@@ -0,0 +1,91 @@
+package models
+
+// Roles a user can have on a material or folder
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor" // May add, change, move and delete items inside the shared folder
+	RoleViewer = "viewer" // May open and download the shared items
+)
+
+// SharedRootID is the ID of the virtual folder listing what other users
+// shared with the user
+const SharedRootID = "shared"
+
+// Share grants another user access to a node and its descendants
+type Share struct {
+	UserID   int64  `json:"userId"`
+	Role     string `json:"role"`
+	SharedAt string `json:"sharedAt"`
+}
+
+// SharedRef points to a node another user shared with the user
+type SharedRef struct {
+	OwnerID int64  `json:"ownerId"`
+	NodeID  string `json:"nodeId"`
+}
+
+// SharedWithMeData holds the nodes other users shared with a user
+type SharedWithMeData struct {
+	Items []SharedRef `json:"items"`
+}
+
+// ShareRequest represents the request to share a node with another user
+type ShareRequest struct {
+	Email string `json:"email" binding:"required"`
+	Role  string `json:"role" binding:"required"`
+}
+
+// ShareInfo describes a share of a node to its owner
+type ShareInfo struct {
+	UserID   int64  `json:"userId"`
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	SharedAt string `json:"sharedAt"`
+}
+
+// SharedNode is a node shared with the user, listed in the virtual
+// "Compartilhados comigo" folder
+type SharedNode struct {
+	*MaterialNode
+	OwnerID    int64  `json:"ownerId"`
+	OwnerName  string `json:"ownerName"`
+	OwnerEmail string `json:"ownerEmail"`
+	Role       string `json:"role"`
+	Revision   int64  `json:"revision"` // Revision of the owner's tree, for If-Match
+}
+
+// ShareLink describes a public link to a node to its owner. The token of
+// the link is only returned when it is created.
+type ShareLink struct {
+	ID           string `json:"id"`
+	NodeID       string `json:"nodeId"`
+	Name         string `json:"name,omitempty"` // Current name of the node
+	URL          string `json:"url,omitempty"`  // Only set when the link is created
+	CreatedAt    string `json:"createdAt"`
+	ExpiresAt    string `json:"expiresAt,omitempty"`
+	MaxDownloads int    `json:"maxDownloads,omitempty"` // 0 means unlimited
+	Downloads    int    `json:"downloads"`
+	HasPassword  bool   `json:"hasPassword"`
+}
+
+// CreateShareLinkRequest represents the request to create a public link.
+// ExpiresAt is an RFC 3339 time or a date, valid until the end of that day.
+type CreateShareLinkRequest struct {
+	ExpiresAt    string `json:"expiresAt"`
+	MaxDownloads int    `json:"maxDownloads"`
+	Password     string `json:"password"`
+}
+
+// OpenShareLinkRequest represents the password sent to open a public link
+type OpenShareLinkRequest struct {
+	Password string `json:"password"`
+}
+
+// ShareLinkFileRequest represents the request for the file URL of a
+// material of a public link. Access is the token returned when a link
+// with a password is opened.
+type ShareLinkFileRequest struct {
+	Access   string `json:"access"`
+	Download bool   `json:"download"`
+}
@@ -0,0 +1,65 @@
+package models
+
+// StudyStats represents the aggregated study statistics of a user.
+// All dates are in the time zone the statistics were computed for.
+type StudyStats struct {
+	Timezone  string          `json:"timezone"`
+	Today     string          `json:"today"` // YYYY-MM-DD
+	Totals    StudyTotals     `json:"totals"`
+	Streaks   StudyStreaks    `json:"streaks"`
+	Daily     []DayMinutes    `json:"daily"`
+	Weekly    []PeriodMinutes `json:"weekly"`  // Weeks start on Monday
+	Monthly   []PeriodMinutes `json:"monthly"` // Periods are YYYY-MM
+	Heatmap   []HeatmapDay    `json:"heatmap"`
+	Subjects  []SubjectStats  `json:"subjects"`
+	Reminders ReminderStats   `json:"reminders"`
+}
+
+// StudyTotals represents study minutes in the current periods
+type StudyTotals struct {
+	Today   int `json:"today"`
+	Week    int `json:"week"`
+	Month   int `json:"month"`
+	AllTime int `json:"allTime"`
+}
+
+// StudyStreaks represents runs of consecutive days with study time
+type StudyStreaks struct {
+	Current int `json:"current"`
+	Longest int `json:"longest"`
+}
+
+// DayMinutes represents the study minutes of a day
+type DayMinutes struct {
+	Date    string `json:"date"`
+	Minutes int    `json:"minutes"`
+}
+
+// PeriodMinutes represents the study minutes of a week or month
+type PeriodMinutes struct {
+	Period  string `json:"period"`
+	Minutes int    `json:"minutes"`
+}
+
+// HeatmapDay represents a heatmap cell with an intensity level from 0 to 4
+type HeatmapDay struct {
+	Date    string `json:"date"`
+	Minutes int    `json:"minutes"`
+	Level   int    `json:"level"`
+}
+
+// SubjectStats represents the focused time recorded for a subject.
+// Sessions without a subject are grouped under an empty name.
+type SubjectStats struct {
+	Subject  string `json:"subject"`
+	Minutes  int    `json:"minutes"`
+	Sessions int    `json:"sessions"`
+}
+
+// ReminderStats represents reminder completion
+type ReminderStats struct {
+	Total          int     `json:"total"`
+	Completed      int     `json:"completed"`
+	Pending        int     `json:"pending"`
+	CompletionRate float64 `json:"completionRate"` // From 0 to 1
+}
@@ -0,0 +1,34 @@
+package models
+
+// Kinds of items in the trash
+const (
+	TrashMaterial = "material" // A material or folder with its contents
+	TrashNote     = "note"
+	TrashEvent    = "event"
+)
+
+// TrashFolder identifies a folder on the path of a deleted material, so it
+// can be recreated when the material is restored
+type TrashFolder struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// TrashItem is a deleted item kept until it is restored, purged or expires
+type TrashItem struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	DeletedAt string `json:"deletedAt"`
+	ExpiresAt string `json:"expiresAt"`
+	// Path lists the folders from the root to the deleted material
+	Path  []TrashFolder `json:"path,omitempty"`
+	Node  *MaterialNode `json:"node,omitempty"`
+	Note  *Note         `json:"note,omitempty"`
+	Event *Event        `json:"event,omitempty"`
+}
+
+// TrashData holds a user's deleted items, oldest first
+type TrashData struct {
+	Items []TrashItem `json:"items"`
+}
@@ -0,0 +1,32 @@
+package models
+
+// UploadSession is a resumable upload of a large material. The client sends
+// the file in chunks and finishes the session into a material.
+type UploadSession struct {
+	ID        string `json:"id"`
+	FileName  string `json:"fileName"`
+	Size      int64  `json:"size"`               // Declared file size in bytes
+	Offset    int64  `json:"offset"`             // Bytes received so far
+	FilePath  string `json:"filePath,omitempty"` // Stored file, set once all bytes were received
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// UploadSessionsData holds a user's open upload sessions
+type UploadSessionsData struct {
+	Sessions []UploadSession `json:"sessions"`
+}
+
+// CreateUploadRequest represents the body to start a resumable upload
+type CreateUploadRequest struct {
+	FileName string `json:"fileName" binding:"required"`
+	FileSize int64  `json:"fileSize" binding:"required"`
+}
+
+// FinishUploadRequest represents the body to turn a completed upload into a material
+type FinishUploadRequest struct {
+	Name         string `json:"name" binding:"required"`
+	ParentID     string `json:"parentId" binding:"required"`
+	MaterialType string `json:"materialType" binding:"required"`
+	Description  string `json:"description"`
+}
@@ -0,0 +1,31 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"studybuddy/mail"
+)
+
+// EmailChannel sends notifications by email
+type EmailChannel struct {
+	Sender mail.Sender
+}
+
+// Name returns the channel name
+func (EmailChannel) Name() string {
+	return "email"
+}
+
+// Enabled reports whether the user wants email notifications
+func (EmailChannel) Enabled(target Target) bool {
+	return target.Settings.Email && target.User.Email != ""
+}
+
+// Send emails the notification to the user
+func (c EmailChannel) Send(_ context.Context, target Target, notification Notification) error {
+	return c.Sender.Send(mail.Message{
+		To:      target.User.Email,
+		Subject: "StudyBuddy: " + notification.Title,
+		Body:    fmt.Sprintf("Olá, %s!\n\n%s\n\n%s", target.User.Name, notification.Body, notification.URL),
+	})
+}
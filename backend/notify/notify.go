@@ -0,0 +1,36 @@
+// Package notify delivers reminder and event notifications through
+// pluggable channels and runs the scheduler that triggers them.
+package notify
+
+import (
+	"context"
+	"studybuddy/models"
+)
+
+// Notification represents one occurrence of a reminder or event to be
+// announced to a user
+type Notification struct {
+	Type   string `json:"type"` // event or reminder
+	ItemID int64  `json:"id"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	Date   string `json:"date"`
+	Time   string `json:"time"`
+	URL    string `json:"url"`
+}
+
+// Target holds what channels need to reach a user
+type Target struct {
+	User          models.User
+	Settings      models.NotificationSettings
+	Subscriptions []models.PushSubscription
+}
+
+// Channel delivers notifications through one medium
+type Channel interface {
+	// Name identifies the channel in delivery records
+	Name() string
+	// Enabled reports whether the user opted in and can be reached
+	Enabled(target Target) bool
+	Send(ctx context.Context, target Target, notification Notification) error
+}
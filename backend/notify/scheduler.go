@@ -0,0 +1,313 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"studybuddy/mail"
+	"studybuddy/models"
+	"studybuddy/recurrence"
+	"studybuddy/storage"
+	"sync"
+	"time"
+)
+
+// Scheduler defaults
+const (
+	defaultInterval = time.Minute
+	// allDayTime is when all-day items are announced
+	allDayTime = "08:00"
+	// staleAfter stops announcing items that passed long ago, e.g. after downtime
+	staleAfter = 12 * time.Hour
+	// maxAttempts is how many times a failing delivery is retried
+	maxAttempts = 3
+	// keepDeliveries is how long delivery records are kept
+	keepDeliveries = 7 * 24 * time.Hour
+	// scanWorkers is how many users are processed at the same time
+	scanWorkers = 8
+)
+
+// Scheduler periodically scans users' reminders and events and sends the
+// due ones through the channels each user enabled
+type Scheduler struct {
+	Channels []Channel
+	Interval time.Duration
+	BaseURL  string // Link included in notifications
+}
+
+// NewSchedulerFromEnv returns a scheduler with email, webhook and, when VAPID
+// keys are available, Web Push channels. NOTIFY_INTERVAL sets the scan
+// interval (default 1m).
+func NewSchedulerFromEnv(sender mail.Sender, baseURL string) *Scheduler {
+	scheduler := &Scheduler{
+		Channels: []Channel{EmailChannel{Sender: sender}, NewWebhookChannel()},
+		Interval: defaultInterval,
+		BaseURL:  baseURL,
+	}
+	if value := os.Getenv("NOTIFY_INTERVAL"); value != "" {
+		if interval, err := time.ParseDuration(value); err == nil && interval > 0 {
+			scheduler.Interval = interval
+		} else {
+			log.Printf("WARNING: Invalid NOTIFY_INTERVAL %q, using %s", value, defaultInterval)
+		}
+	}
+
+	keys, err := storage.LoadVAPIDKeys()
+	if err != nil {
+		log.Printf("WARNING: Web Push disabled: %v", err)
+		return scheduler
+	}
+	subject := os.Getenv("VAPID_SUBJECT")
+	if subject == "" {
+		subject = "mailto:admin@studybuddy.local"
+	}
+	push, err := NewPushChannel(keys, subject)
+	if err != nil {
+		log.Printf("WARNING: Web Push disabled: %v", err)
+		return scheduler
+	}
+	scheduler.Channels = append(scheduler.Channels, push)
+	return scheduler
+}
+
+// PushPublicKey returns the VAPID public key of the Web Push channel, or an
+// empty string when push is disabled
+func (s *Scheduler) PushPublicKey() string {
+	for _, channel := range s.Channels {
+		if push, ok := channel.(*PushChannel); ok {
+			return push.publicKey
+		}
+	}
+	return ""
+}
+
+// Run scans for due notifications every Interval until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.Scan(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan sends the notifications due at now for every user, processing up to
+// scanWorkers users concurrently. The scan ends by the next tick; pending
+// notifications are sent by the following scans.
+func (s *Scheduler) Scan(ctx context.Context, now time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, s.Interval)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, scanWorkers)
+users:
+	for _, user := range storage.ListUsers() {
+		select {
+		case <-ctx.Done():
+			break users
+		case workers <- struct{}{}:
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-workers; wg.Done() }()
+			if err := s.scanUser(ctx, user, now); err != nil {
+				log.Printf("ERROR: Could not process notifications for user %d: %v", user.ID, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// scanUser sends a user's due notifications and records their delivery
+func (s *Scheduler) scanUser(ctx context.Context, user models.User, now time.Time) error {
+	state, err := storage.LoadNotifications(user.ID)
+	if err != nil {
+		return err
+	}
+	settings := state.Settings
+	if !settings.Enabled {
+		return nil
+	}
+
+	loc := time.Local
+	if settings.Timezone != "" {
+		if tz, err := time.LoadLocation(settings.Timezone); err == nil {
+			loc = tz
+		}
+	}
+	now = now.In(loc)
+	if inQuietHours(settings.QuietHours, now) {
+		return nil // Due items are sent when the quiet hours end
+	}
+
+	target := Target{User: user, Settings: settings, Subscriptions: state.Subscriptions}
+	channels := make([]Channel, 0, len(s.Channels))
+	for _, channel := range s.Channels {
+		if channel.Enabled(target) {
+			channels = append(channels, channel)
+		}
+	}
+	if len(channels) == 0 {
+		return nil
+	}
+
+	data, err := storage.LoadData(user.ID)
+	if err != nil {
+		return err
+	}
+
+	results := make(map[string]models.NotificationDelivery)
+	for _, notification := range s.dueNotifications(data, settings, now) {
+		for _, channel := range channels {
+			key := deliveryKey(notification, channel.Name())
+			delivery := state.Deliveries[key]
+			if delivery.Delivered || delivery.Attempts >= maxAttempts {
+				continue
+			}
+			if ctx.Err() != nil {
+				break // The scan is over: the next one sends it without losing an attempt
+			}
+
+			delivery.Date = notification.Date
+			delivery.Attempts++
+			delivery.LastAttempt = time.Now().Format(time.RFC3339)
+			if err := channel.Send(ctx, target, notification); err != nil {
+				delivery.Error = err.Error()
+				log.Printf("WARNING: %s notification to user %d failed: %v", channel.Name(), user.ID, err)
+			} else {
+				delivery.Delivered = true
+				delivery.Error = ""
+			}
+			results[key] = delivery
+		}
+	}
+
+	if len(results) == 0 {
+		return nil
+	}
+	_, err = storage.UpdateNotifications(user.ID, func(state *models.NotificationsData) error {
+		if state.Deliveries == nil {
+			state.Deliveries = make(map[string]models.NotificationDelivery)
+		}
+		for key, delivery := range results {
+			state.Deliveries[key] = delivery
+		}
+		pruneDeliveries(state.Deliveries, now)
+		return nil
+	})
+	return err
+}
+
+// dueNotifications returns the occurrences whose notification time, LeadMinutes
+// before the item, has passed without the item being stale
+func (s *Scheduler) dueNotifications(data models.AppData, settings models.NotificationSettings, now time.Time) []Notification {
+	lead := time.Duration(settings.LeadMinutes) * time.Minute
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from, to := today.AddDate(0, 0, -1), today.AddDate(0, 0, 1)
+
+	// due reports whether an occurrence at date and clock must be announced now
+	due := func(date, clock string) bool {
+		if clock == "" {
+			clock = allDayTime
+		}
+		at, err := time.ParseInLocation("2006-01-02 15:04", date+" "+clock, now.Location())
+		if err != nil {
+			return false
+		}
+		return !now.Before(at.Add(-lead)) && now.Sub(at) < staleAfter
+	}
+
+	var notifications []Notification
+	for _, reminder := range data.Reminders {
+		if reminder.Completed {
+			continue
+		}
+		for _, date := range recurrence.Occurrences(reminder.Recurrence, reminder.Date, from, to) {
+			if !due(date, reminder.Time) || containsDate(reminder.CompletedDates, date) {
+				continue
+			}
+			notifications = append(notifications, Notification{
+				Type:   "reminder",
+				ItemID: reminder.ID,
+				Title:  reminder.Title,
+				Body:   describe("Lembrete", reminder.Title, date, reminder.Time),
+				Date:   date,
+				Time:   reminder.Time,
+				URL:    s.BaseURL,
+			})
+		}
+	}
+	for _, event := range data.Events {
+		for _, date := range recurrence.Occurrences(event.Recurrence, event.Date, from, to) {
+			if !due(date, event.Time) {
+				continue
+			}
+			notifications = append(notifications, Notification{
+				Type:   "event",
+				ItemID: event.ID,
+				Title:  event.Title,
+				Body:   describe("Evento", event.Title, date, event.Time),
+				Date:   date,
+				Time:   event.Time,
+				URL:    s.BaseURL,
+			})
+		}
+	}
+	return notifications
+}
+
+// describe builds the text of a notification
+func describe(kind, title, date, clock string) string {
+	when := date
+	if parsed, err := time.Parse("2006-01-02", date); err == nil {
+		when = parsed.Format("02/01/2006")
+	}
+	if clock != "" {
+		when += " às " + clock
+	}
+	return fmt.Sprintf("%s: %s (%s)", kind, title, when)
+}
+
+// deliveryKey identifies the delivery of one occurrence on one channel
+func deliveryKey(notification Notification, channel string) string {
+	return fmt.Sprintf("%s:%d:%s:%s", notification.Type, notification.ItemID, notification.Date, channel)
+}
+
+// pruneDeliveries drops records of occurrences older than keepDeliveries
+func pruneDeliveries(deliveries map[string]models.NotificationDelivery, now time.Time) {
+	cutoff := now.Add(-keepDeliveries).Format("2006-01-02")
+	for key, delivery := range deliveries {
+		if delivery.Date < cutoff {
+			delete(deliveries, key)
+		}
+	}
+}
+
+// inQuietHours reports whether now falls in the quiet window, which may
+// cross midnight
+func inQuietHours(quiet models.QuietHours, now time.Time) bool {
+	if quiet.Start == "" || quiet.End == "" || quiet.Start == quiet.End {
+		return false
+	}
+	clock := now.Format("15:04")
+	if quiet.Start < quiet.End {
+		return clock >= quiet.Start && clock < quiet.End
+	}
+	return clock >= quiet.Start || clock < quiet.End
+}
+
+// containsDate reports whether dates contains date
+func containsDate(dates []string, date string) bool {
+	for _, existing := range dates {
+		if existing == date {
+			return true
+		}
+	}
+	return false
+}
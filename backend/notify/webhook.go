@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// webhookTimeout limits each webhook request
+const webhookTimeout = 10 * time.Second
+
+// WebhookChannel posts notifications as JSON to a URL chosen by the user
+type WebhookChannel struct {
+	Client *http.Client
+}
+
+// NewWebhookChannel returns a webhook channel with a request timeout that
+// only reaches public addresses
+func NewWebhookChannel() WebhookChannel {
+	return WebhookChannel{Client: newOutboundClient()}
+}
+
+// Name returns the channel name
+func (WebhookChannel) Name() string {
+	return "webhook"
+}
+
+// Enabled reports whether the user configured a webhook
+func (WebhookChannel) Enabled(target Target) bool {
+	return target.Settings.Webhook && target.Settings.WebhookURL != ""
+}
+
+// Send posts the notification to the user's webhook
+func (c WebhookChannel) Send(ctx context.Context, target Target, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.Settings.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "StudyBuddy-Notifier")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook respondeu com status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"studybuddy/storage"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Web Push parameters
+const (
+	pushTTL        = 24 * time.Hour
+	pushRecordSize = 4096
+	vapidTokenTTL  = 12 * time.Hour
+)
+
+// PushChannel sends notifications with the Web Push protocol (RFC 8030),
+// encrypting payloads with aes128gcm (RFC 8291) and identifying the server
+// with VAPID (RFC 8292)
+type PushChannel struct {
+	Client     *http.Client
+	Subject    string // Contact URI sent to push services, e.g. mailto:admin@example.com
+	publicKey  string
+	privateKey *ecdsa.PrivateKey
+}
+
+// NewPushChannel returns a push channel signing requests with the given keys
+func NewPushChannel(keys storage.VAPIDKeys, subject string) (*PushChannel, error) {
+	public, err := base64.RawURLEncoding.DecodeString(keys.PublicKey)
+	if err != nil || len(public) != 65 || public[0] != 4 {
+		return nil, errors.New("invalid VAPID public key")
+	}
+	private, err := base64.RawURLEncoding.DecodeString(keys.PrivateKey)
+	if err != nil || len(private) != 32 {
+		return nil, errors.New("invalid VAPID private key")
+	}
+
+	return &PushChannel{
+		Client:    newOutboundClient(),
+		Subject:   subject,
+		publicKey: keys.PublicKey,
+		privateKey: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(public[1:33]),
+				Y:     new(big.Int).SetBytes(public[33:]),
+			},
+			D: new(big.Int).SetBytes(private),
+		},
+	}, nil
+}
+
+// Name returns the channel name
+func (*PushChannel) Name() string {
+	return "push"
+}
+
+// Enabled reports whether the user wants push notifications and has a subscribed browser
+func (*PushChannel) Enabled(target Target) bool {
+	return target.Settings.Push && len(target.Subscriptions) > 0
+}
+
+// Send pushes the notification to every subscribed browser. Subscriptions
+// rejected as expired are removed. It fails only if no browser accepted it.
+func (c *PushChannel) Send(ctx context.Context, target Target, notification Notification) error {
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	delivered := false
+	for _, subscription := range target.Subscriptions {
+		status, err := c.push(ctx, subscription.Endpoint, subscription.Keys.P256dh, subscription.Keys.Auth, payload)
+		if status == http.StatusNotFound || status == http.StatusGone {
+			if err := storage.RemovePushSubscription(target.User.ID, subscription.Endpoint); err != nil {
+				log.Printf("ERROR: Could not remove expired push subscription: %v", err)
+			}
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		delivered = true
+	}
+
+	if !delivered {
+		return lastErr
+	}
+	return nil
+}
+
+// push sends an encrypted payload to one subscription endpoint
+func (c *PushChannel) push(ctx context.Context, endpoint, p256dh, auth string, payload []byte) (int, error) {
+	body, err := encryptPayload(p256dh, auth, payload)
+	if err != nil {
+		return 0, err
+	}
+
+	authorization, err := c.vapidAuthorization(endpoint)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", fmt.Sprintf("%d", int(pushTTL.Seconds())))
+	req.Header.Set("Urgency", "high")
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("serviço de push respondeu com status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// vapidAuthorization returns the VAPID Authorization header for an endpoint
+func (c *PushChannel) vapidAuthorization(endpoint string) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.MapClaims{
+		"aud": parsed.Scheme + "://" + parsed.Host,
+		"exp": time.Now().Add(vapidTokenTTL).Unix(),
+		"sub": c.Subject,
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(c.privateKey)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("vapid t=%s, k=%s", token, c.publicKey), nil
+}
+
+// encryptPayload encrypts a payload for a subscription as a single
+// aes128gcm record (RFC 8291)
+func encryptPayload(p256dh, auth string, payload []byte) ([]byte, error) {
+	clientPublic, err := decodeKey(p256dh)
+	if err != nil {
+		return nil, err
+	}
+	authSecret, err := decodeKey(auth)
+	if err != nil {
+		return nil, err
+	}
+
+	userAgentKey, err := ecdh.P256().NewPublicKey(clientPublic)
+	if err != nil {
+		return nil, err
+	}
+	serverKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := serverKey.ECDH(userAgentKey)
+	if err != nil {
+		return nil, err
+	}
+	serverPublic := serverKey.PublicKey().Bytes()
+
+	keyInfo := "WebPush: info\x00" + string(clientPublic) + string(serverPublic)
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	contentKey, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// 0x02 marks the last (and only) record
+	plaintext := append(append(make([]byte, 0, len(payload)+1), payload...), 0x02)
+	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
+
+	header := make([]byte, 0, 16+4+1+len(serverPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, pushRecordSize)
+	header = append(header, byte(len(serverPublic)))
+	header = append(header, serverPublic...)
+	return append(header, ciphertext...), nil
+}
+
+// decodeKey decodes a base64url key, padded or not
+func decodeKey(value string) ([]byte, error) {
+	if decoded, err := base64.RawURLEncoding.DecodeString(value); err == nil {
+		return decoded, nil
+	}
+	return base64.URLEncoding.DecodeString(value)
+}
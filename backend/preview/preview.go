@@ -0,0 +1,201 @@
+// Package preview generates thumbnails of uploaded materials: scaled
+// images and a render of the text layout of the first page of PDFs.
+package preview
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"os"
+	"strings"
+
+	// Decoders of the image types accepted as uploads
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// MaxSize is the maximum width and height of a thumbnail in pixels
+const MaxSize = 256
+
+// maxPixels refuses to decode images larger than this (about 96 MB once
+// decoded), protecting against decompression bombs
+const maxPixels = 24_000_000
+
+// jpegQuality is the quality thumbnails are encoded with
+const jpegQuality = 80
+
+// Kinds of material previews
+const (
+	KindImage    = "image"
+	KindPDF      = "pdf"
+	KindVideo    = "video"
+	KindAudio    = "audio"
+	KindDocument = "document"
+)
+
+// ErrUnsupported is returned for files without a thumbnail
+var ErrUnsupported = errors.New("pré-visualização indisponível para este tipo de arquivo")
+
+// Kind returns the preview kind of a MIME type
+func Kind(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		return KindImage
+	case strings.HasPrefix(contentType, "application/pdf"):
+		return KindPDF
+	case strings.HasPrefix(contentType, "video/"):
+		return KindVideo
+	case strings.HasPrefix(contentType, "audio/"):
+		return KindAudio
+	default:
+		return KindDocument
+	}
+}
+
+// Generate writes a JPEG thumbnail of the file at path to w. It returns
+// ErrUnsupported for kinds without a thumbnail.
+func Generate(w io.Writer, path, contentType string) error {
+	var thumbnail image.Image
+	var err error
+	switch Kind(contentType) {
+	case KindImage:
+		thumbnail, err = imageThumbnail(path)
+	case KindPDF:
+		thumbnail, err = pdfThumbnail(path)
+	default:
+		return ErrUnsupported
+	}
+	if err != nil {
+		return err
+	}
+	return jpeg.Encode(w, thumbnail, &jpeg.Options{Quality: jpegQuality})
+}
+
+// imageThumbnail scales an image to fit MaxSize
+func imageThumbnail(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("dimensões de imagem não suportadas: %dx%d", config.Width, config.Height)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := fit(config.Width, config.Height)
+	dst := whiteCanvas(width, height)
+	// Transparent images are composed over white, as JPEG has no alpha
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+	return dst, nil
+}
+
+// pdfThumbnail renders the text layout of the first page of a PDF. Text
+// large enough to read at thumbnail size, such as slide titles, is drawn
+// as glyphs; smaller text is drawn as grey lines.
+func pdfThumbnail(path string) (thumbnail image.Image, err error) {
+	// The PDF parser panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			thumbnail, err = nil, fmt.Errorf("PDF inválido: %v", r)
+		}
+	}()
+
+	f, reader, err := pdf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if reader.NumPage() < 1 {
+		return nil, errors.New("PDF sem páginas")
+	}
+
+	page := reader.Page(1)
+	box := mediaBox(page)
+	pageWidth := box.Index(2).Float64() - box.Index(0).Float64()
+	pageHeight := box.Index(3).Float64() - box.Index(1).Float64()
+	if pageWidth <= 0 || pageHeight <= 0 {
+		// Letter size when the page has no valid MediaBox
+		pageWidth, pageHeight = 612, 792
+	}
+
+	width, height := fit(int(pageWidth), int(pageHeight))
+	scale := float64(width) / pageWidth
+	dst := whiteCanvas(width, height)
+
+	face := basicfont.Face7x13
+	lineColor := image.NewUniform(color.Gray{Y: 190})
+	drawer := &font.Drawer{Dst: dst, Src: image.NewUniform(color.Gray{Y: 40}), Face: face}
+	originX, originY := box.Index(0).Float64(), box.Index(1).Float64()
+
+	for _, text := range page.Content().Text {
+		if strings.TrimSpace(text.S) == "" {
+			continue
+		}
+		x := int((text.X - originX) * scale)
+		// PDF coordinates grow upwards
+		y := height - int((text.Y-originY)*scale)
+		size := text.FontSize * scale
+
+		if size >= float64(face.Height) {
+			drawer.Dot = fixed.P(x, y)
+			drawer.DrawString(text.S)
+			continue
+		}
+		lineHeight := max(1, int(size*0.6))
+		lineWidth := max(1, int(text.W*scale))
+		rect := image.Rect(x, y-lineHeight, x+lineWidth, y)
+		draw.Draw(dst, rect.Intersect(dst.Bounds()), lineColor, image.Point{}, draw.Src)
+	}
+	return dst, nil
+}
+
+// mediaBox returns the page's MediaBox, which may be inherited from the
+// page tree
+func mediaBox(page pdf.Page) pdf.Value {
+	for v := page.V; !v.IsNull(); v = v.Key("Parent") {
+		if box := v.Key("MediaBox"); !box.IsNull() {
+			return box
+		}
+	}
+	return pdf.Value{}
+}
+
+// fit returns the size of a thumbnail of a width x height source, keeping
+// the aspect ratio. Sources smaller than MaxSize are not enlarged.
+func fit(width, height int) (int, int) {
+	if width <= MaxSize && height <= MaxSize {
+		return max(width, 1), max(height, 1)
+	}
+	if width >= height {
+		return MaxSize, max(1, height*MaxSize/width)
+	}
+	return max(1, width*MaxSize/height), MaxSize
+}
+
+// whiteCanvas returns a white RGBA image
+func whiteCanvas(width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	return dst
+}
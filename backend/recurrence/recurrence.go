@@ -0,0 +1,319 @@
+// Package recurrence validates and expands the repetition rules of events
+// and reminders.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"studybuddy/models"
+	"time"
+)
+
+// dateLayout is the format of the dates in events, reminders and rules
+const dateLayout = "2006-01-02"
+
+// maxInterval limits the interval between repetitions
+const maxInterval = 365
+
+// Validate checks a rule for an item starting on start (YYYY-MM-DD).
+// Errors carry a message suitable for the user.
+func Validate(rule *models.Recurrence, start string) error {
+	first, err := time.Parse(dateLayout, start)
+	if err != nil {
+		return errors.New("Data inválida. Use o formato AAAA-MM-DD")
+	}
+
+	switch rule.Frequency {
+	case models.RecurDaily, models.RecurMonthly:
+		if len(rule.Weekdays) > 0 {
+			return errors.New("Dias da semana só podem ser usados na repetição semanal")
+		}
+	case models.RecurWeekly:
+		for _, weekday := range rule.Weekdays {
+			if weekday < 0 || weekday > 6 {
+				return errors.New("Dia da semana inválido. Use 0 (domingo) a 6 (sábado)")
+			}
+		}
+	default:
+		return errors.New("Frequência inválida. Use daily, weekly ou monthly")
+	}
+
+	if rule.Interval < 0 || rule.Interval > maxInterval {
+		return fmt.Errorf("O intervalo deve estar entre 1 e %d", maxInterval)
+	}
+	if rule.Count < 0 {
+		return errors.New("O número de repetições não pode ser negativo")
+	}
+	if rule.Until != "" {
+		if rule.Count > 0 {
+			return errors.New("Use apenas uma data final ou um número de repetições")
+		}
+		until, err := time.Parse(dateLayout, rule.Until)
+		if err != nil {
+			return errors.New("Data final inválida. Use o formato AAAA-MM-DD")
+		}
+		if until.Before(first) {
+			return errors.New("A data final deve ser posterior à data inicial")
+		}
+	}
+	for _, exception := range rule.Exceptions {
+		if _, err := time.Parse(dateLayout, exception); err != nil {
+			return errors.New("Exceção inválida. Use o formato AAAA-MM-DD")
+		}
+	}
+	return nil
+}
+
+// Occurrences returns the dates (YYYY-MM-DD) in [from, to] on which an item
+// starting on start occurs. A nil rule means a single occurrence on start.
+func Occurrences(rule *models.Recurrence, start string, from, to time.Time) []string {
+	first, err := time.Parse(dateLayout, start)
+	if err != nil {
+		return nil
+	}
+	from, to = truncate(from), truncate(to)
+
+	if rule == nil {
+		if first.Before(from) || first.After(to) {
+			return nil
+		}
+		return []string{start}
+	}
+
+	interval := rule.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	// Expansion stops at the end of the range or the rule's last date
+	until := to
+	if parsed, err := time.Parse(dateLayout, rule.Until); err == nil && parsed.Before(until) {
+		until = parsed
+	}
+	exceptions := make(map[string]bool, len(rule.Exceptions))
+	for _, exception := range rule.Exceptions {
+		exceptions[exception] = true
+	}
+
+	var result []string
+	count := 0
+	// emit records a candidate date and reports whether expansion continues
+	emit := func(date time.Time) bool {
+		if date.After(until) {
+			return false
+		}
+		count++
+		if rule.Count > 0 && count > rule.Count {
+			return false
+		}
+		day := date.Format(dateLayout)
+		if !date.Before(from) && !exceptions[day] {
+			result = append(result, day)
+		}
+		return true
+	}
+
+	switch rule.Frequency {
+	case models.RecurDaily:
+		for date := first; emit(date); date = date.AddDate(0, 0, interval) {
+		}
+
+	case models.RecurWeekly:
+		weekdays := weekdayOffsets(rule.Weekdays, first)
+		monday := first.AddDate(0, 0, -mondayOffset(first.Weekday()))
+	weeks:
+		for week := monday; !week.After(until); week = week.AddDate(0, 0, 7*interval) {
+			for _, offset := range weekdays {
+				date := week.AddDate(0, 0, offset)
+				if date.Before(first) {
+					continue
+				}
+				if !emit(date) {
+					break weeks
+				}
+			}
+		}
+
+	case models.RecurMonthly:
+		// Months without the start day are skipped, as in RFC 5545
+		for months := 0; ; months += interval {
+			monthStart := time.Date(first.Year(), first.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+			if monthStart.After(until) {
+				break
+			}
+			date := time.Date(first.Year(), first.Month()+time.Month(months), first.Day(), 0, 0, 0, 0, time.UTC)
+			if date.Day() != first.Day() {
+				continue
+			}
+			if !emit(date) {
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+// IsOccurrence reports whether an item starting on start occurs on date
+func IsOccurrence(rule *models.Recurrence, start, date string) bool {
+	day, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return false
+	}
+	return len(Occurrences(rule, start, day, day)) == 1
+}
+
+// weekdayNames are the iCalendar names of the weekdays, from Sunday
+var weekdayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// RRule returns the rule as an iCalendar RRULE value for an item starting
+// at start. UNTIL has the value type of DTSTART, as RFC 5545 requires: a
+// date for all-day items, otherwise the floating time of the last
+// occurrence.
+func RRule(rule *models.Recurrence, start time.Time, allDay bool) string {
+	parts := []string{"FREQ=" + strings.ToUpper(rule.Frequency)}
+	if rule.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", rule.Interval))
+	}
+	if rule.Frequency == models.RecurWeekly && len(rule.Weekdays) > 0 {
+		days := make([]string, 0, len(rule.Weekdays))
+		for _, weekday := range rule.Weekdays {
+			// Rules saved before validation may hold invalid weekdays
+			if weekday >= 0 && weekday < len(weekdayNames) {
+				days = append(days, weekdayNames[weekday])
+			}
+		}
+		if len(days) > 0 {
+			parts = append(parts, "BYDAY="+strings.Join(days, ","))
+		}
+	}
+	if until, err := time.Parse(dateLayout, rule.Until); err == nil {
+		value := until.Format("20060102")
+		if !allDay {
+			value += start.Format("T150405")
+		}
+		parts = append(parts, "UNTIL="+value)
+	}
+	if rule.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", rule.Count))
+	}
+	return strings.Join(parts, ";")
+}
+
+// ErrUnsupportedRule is returned for RRULE values that cannot be
+// represented as a models.Recurrence
+var ErrUnsupportedRule = errors.New("regra de repetição não suportada")
+
+// ParseRRule converts an iCalendar RRULE value into a rule. Only the
+// daily, weekly and monthly rules the application can repeat are
+// supported; UTC UNTIL times are converted to the server's local date.
+func ParseRRule(value string) (*models.Recurrence, error) {
+	rule := &models.Recurrence{}
+	for _, part := range strings.Split(value, ";") {
+		name, param, _ := strings.Cut(part, "=")
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			switch strings.ToUpper(param) {
+			case "DAILY":
+				rule.Frequency = models.RecurDaily
+			case "WEEKLY":
+				rule.Frequency = models.RecurWeekly
+			case "MONTHLY":
+				rule.Frequency = models.RecurMonthly
+			default:
+				return nil, ErrUnsupportedRule
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(param)
+			if err != nil {
+				return nil, ErrUnsupportedRule
+			}
+			rule.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(param)
+			if err != nil {
+				return nil, ErrUnsupportedRule
+			}
+			rule.Count = count
+		case "UNTIL":
+			until, err := parseUntil(param)
+			if err != nil {
+				return nil, ErrUnsupportedRule
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(strings.ToUpper(param), ",") {
+				weekday := indexOf(weekdayNames, day)
+				// Positions such as 2MO (the second Monday) are not supported
+				if weekday < 0 {
+					return nil, ErrUnsupportedRule
+				}
+				rule.Weekdays = append(rule.Weekdays, weekday)
+			}
+		case "WKST":
+			// The week start does not change daily, monthly or single-day
+			// weekly rules, and the application's weeks start on Monday
+		default:
+			return nil, ErrUnsupportedRule
+		}
+	}
+	if rule.Frequency == "" || (len(rule.Weekdays) > 0 && rule.Frequency != models.RecurWeekly) {
+		return nil, ErrUnsupportedRule
+	}
+	return rule, nil
+}
+
+// parseUntil converts an UNTIL value, a DATE or a DATE-TIME, to a date
+func parseUntil(value string) (string, error) {
+	if len(value) == len("20060102") {
+		until, err := time.Parse("20060102", value)
+		return until.Format(dateLayout), err
+	}
+	if strings.HasSuffix(value, "Z") {
+		until, err := time.Parse("20060102T150405Z", value)
+		return until.Local().Format(dateLayout), err
+	}
+	until, err := time.Parse("20060102T150405", value)
+	return until.Format(dateLayout), err
+}
+
+// indexOf returns the index of value in values, or -1
+func indexOf(values []string, value string) int {
+	for i, existing := range values {
+		if existing == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// weekdayOffsets returns the sorted, unique offsets from Monday of the
+// rule's weekdays, defaulting to the weekday of first
+func weekdayOffsets(weekdays []int, first time.Time) []int {
+	if len(weekdays) == 0 {
+		return []int{mondayOffset(first.Weekday())}
+	}
+	seen := make(map[int]bool, len(weekdays))
+	offsets := make([]int, 0, len(weekdays))
+	for _, weekday := range weekdays {
+		offset := mondayOffset(time.Weekday(weekday))
+		if !seen[offset] {
+			seen[offset] = true
+			offsets = append(offsets, offset)
+		}
+	}
+	sort.Ints(offsets)
+	return offsets
+}
+
+// mondayOffset returns the number of days since Monday
+func mondayOffset(weekday time.Weekday) int {
+	return (int(weekday) + 6) % 7
+}
+
+// truncate returns the UTC midnight of the calendar date of t
+func truncate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
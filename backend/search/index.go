@@ -0,0 +1,288 @@
+// Package search implements the in-process full-text index behind
+// /api/search: accent-insensitive, prefix-matching and ranked.
+package search
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Indexed fields and their weight in the score
+const (
+	fieldTitle = iota
+	fieldText
+)
+
+var fieldWeights = [...]float64{fieldTitle: 3, fieldText: 1}
+
+// prefixPenalty scales the score of terms matched only as a prefix
+const prefixPenalty = 0.5
+
+// snippetRadius is how many characters of context surround the first match
+const snippetRadius = 80
+
+// Document represents an indexed item
+type Document struct {
+	Type    string // note, event, reminder, folder or material
+	ID      string
+	Title   string
+	Text    string // Content or description
+	Subject string
+	Date    string
+}
+
+// Result represents a matching document, with matches wrapped in <mark>
+// and the remaining text HTML-escaped
+type Result struct {
+	Type           string  `json:"type"`
+	ID             string  `json:"id"`
+	Title          string  `json:"title"`
+	Subject        string  `json:"subject,omitempty"`
+	Date           string  `json:"date,omitempty"`
+	Score          float64 `json:"score"`
+	TitleHighlight string  `json:"titleHighlight"`
+	Snippet        string  `json:"snippet,omitempty"`
+}
+
+// Query represents a search request
+type Query struct {
+	Text    string
+	Types   map[string]bool // Empty means all types
+	Subject string          // Compared accent and case insensitively
+}
+
+// posting records the occurrences of a term in one field of a document
+type posting struct {
+	doc   int
+	field int
+	count int
+}
+
+// Index is an immutable inverted index over a set of documents
+type Index struct {
+	docs     []Document
+	postings map[string][]posting
+	terms    []string // Sorted, for prefix lookups
+}
+
+// Build indexes the given documents
+func Build(docs []Document) *Index {
+	index := &Index{docs: docs, postings: make(map[string][]posting)}
+	for i, doc := range docs {
+		for field, text := range [...]string{fieldTitle: doc.Title, fieldText: doc.Text} {
+			counts := make(map[string]int)
+			for _, token := range tokenize(text) {
+				counts[token.term]++
+			}
+			for term, count := range counts {
+				index.postings[term] = append(index.postings[term], posting{doc: i, field: field, count: count})
+			}
+		}
+	}
+
+	index.terms = make([]string, 0, len(index.postings))
+	for term := range index.postings {
+		index.terms = append(index.terms, term)
+	}
+	sort.Strings(index.terms)
+	return index
+}
+
+// Search returns the documents matching every query term, as a word or a
+// word prefix, best matches first
+func (index *Index) Search(query Query) []Result {
+	terms := queryTerms(query.Text)
+	if len(terms) == 0 {
+		return []Result{}
+	}
+	subject := Normalize(query.Subject)
+
+	scores := make(map[int]float64)
+	for i, term := range terms {
+		termScores := index.termScores(term)
+		if i == 0 {
+			scores = termScores
+			continue
+		}
+		// Every term must match
+		for doc, score := range scores {
+			if termScore, ok := termScores[doc]; ok {
+				scores[doc] = score + termScore
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for i, score := range scores {
+		doc := index.docs[i]
+		if len(query.Types) > 0 && !query.Types[doc.Type] {
+			continue
+		}
+		if subject != "" && Normalize(doc.Subject) != subject {
+			continue
+		}
+		results = append(results, Result{
+			Type:           doc.Type,
+			ID:             doc.ID,
+			Title:          doc.Title,
+			Subject:        doc.Subject,
+			Date:           doc.Date,
+			Score:          math.Round(score*1000) / 1000,
+			TitleHighlight: highlight(doc.Title, terms),
+			Snippet:        snippet(doc.Text, terms),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Title < results[j].Title
+	})
+	return results
+}
+
+// termScores scores the documents containing term or a word starting with it
+func (index *Index) termScores(term string) map[int]float64 {
+	scores := make(map[int]float64)
+	start := sort.SearchStrings(index.terms, term)
+	for _, indexed := range index.terms[start:] {
+		if !strings.HasPrefix(indexed, term) {
+			break
+		}
+		postings := index.postings[indexed]
+		idf := math.Log(1 + float64(len(index.docs))/float64(len(postings)))
+		factor := 1.0
+		if indexed != term {
+			factor = prefixPenalty
+		}
+		for _, p := range postings {
+			scores[p.doc] += fieldWeights[p.field] * (1 + math.Log(float64(p.count))) * idf * factor
+		}
+	}
+	return scores
+}
+
+// token is a word of a text with its byte offsets
+type token struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits text into normalized words
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+		if isWord && start == -1 {
+			start = i
+		} else if !isWord && start != -1 {
+			tokens = append(tokens, token{term: Normalize(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start != -1 {
+		tokens = append(tokens, token{term: Normalize(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+// queryTerms returns the unique normalized words of a query
+func queryTerms(text string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, token := range tokenize(text) {
+		if token.term != "" && !seen[token.term] {
+			seen[token.term] = true
+			terms = append(terms, token.term)
+		}
+	}
+	return terms
+}
+
+// Normalize lower-cases text and removes accents, so "Revisão" matches "revisao"
+func Normalize(text string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	result, _, err := transform.String(t, text)
+	if err != nil {
+		result = text
+	}
+	return strings.ToLower(result)
+}
+
+// matches reports whether a word matches any query term
+func matches(term string, terms []string) bool {
+	for _, queryTerm := range terms {
+		if strings.HasPrefix(term, queryTerm) {
+			return true
+		}
+	}
+	return false
+}
+
+// highlight escapes text and wraps the words matching the query in <mark>
+func highlight(text string, terms []string) string {
+	var b strings.Builder
+	last := 0
+	for _, token := range tokenize(text) {
+		if !matches(token.term, terms) {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[last:token.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[token.start:token.end]))
+		b.WriteString("</mark>")
+		last = token.end
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
+
+// snippet returns the highlighted part of text around the first match
+func snippet(text string, terms []string) string {
+	if text == "" {
+		return ""
+	}
+
+	first := -1
+	for _, token := range tokenize(text) {
+		if matches(token.term, terms) {
+			first = token.start
+			break
+		}
+	}
+	if first == -1 {
+		first = 0
+	}
+
+	start := first
+	for n := 0; start > 0 && n < snippetRadius; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+	}
+	end := first
+	for n := 0; end < len(text) && n < 2*snippetRadius; n++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+
+	result := highlight(text[start:end], terms)
+	if start > 0 {
+		result = "…" + result
+	}
+	if end < len(text) {
+		result += "…"
+	}
+	return result
+}
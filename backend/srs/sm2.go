@@ -0,0 +1,66 @@
+// Package srs implements the SM-2 spaced-repetition algorithm used to
+// schedule flashcard reviews.
+package srs
+
+import (
+	"math"
+	"studybuddy/models"
+	"time"
+)
+
+// Scheduler constants from the original SM-2 algorithm
+const (
+	InitialEaseFactor = 2.5
+	minEaseFactor     = 1.3
+	passingGrade      = 3
+	MaxGrade          = 5
+)
+
+// dateLayout is the format of Flashcard.DueDate and LastReviewed
+const dateLayout = "2006-01-02"
+
+// NewCard initializes the schedule of a card so it is due on the given day
+func NewCard(card *models.Flashcard, today time.Time) {
+	card.EaseFactor = InitialEaseFactor
+	card.Interval = 0
+	card.Repetitions = 0
+	card.DueDate = today.Format(dateLayout)
+}
+
+// Review updates a card's schedule after a review with the given grade
+// (0-5). Grades below 3 restart the repetitions; the ease factor is
+// adjusted for every grade.
+func Review(card *models.Flashcard, grade int, today time.Time) {
+	if card.EaseFactor == 0 {
+		card.EaseFactor = InitialEaseFactor
+	}
+
+	if grade < passingGrade {
+		card.Repetitions = 0
+		card.Interval = 1
+	} else {
+		switch card.Repetitions {
+		case 0:
+			card.Interval = 1
+		case 1:
+			card.Interval = 6
+		default:
+			card.Interval = int(math.Round(float64(card.Interval) * card.EaseFactor))
+		}
+		card.Repetitions++
+	}
+
+	q := float64(MaxGrade - grade)
+	card.EaseFactor += 0.1 - q*(0.08+q*0.02)
+	if card.EaseFactor < minEaseFactor {
+		card.EaseFactor = minEaseFactor
+	}
+
+	card.LastReviewed = today.Format(dateLayout)
+	card.DueDate = today.AddDate(0, 0, card.Interval).Format(dateLayout)
+}
+
+// IsDue reports whether a card should be reviewed on the given day
+func IsDue(card models.Flashcard, today time.Time) bool {
+	return card.DueDate <= today.Format(dateLayout)
+}
@@ -0,0 +1,16 @@
+// Service worker do StudyBuddy: exibe as notificações push enviadas pelo servidor
+self.addEventListener('push', (event) => {
+    const data = event.data ? event.data.json() : {};
+    event.waitUntil(
+        self.registration.showNotification(data.title || 'StudyBuddy', {
+            body: data.body || '',
+            tag: `${data.type}-${data.id}-${data.date}`,
+            data: { url: data.url || '/' }
+        })
+    );
+});
+
+self.addEventListener('notificationclick', (event) => {
+    event.notification.close();
+    event.waitUntil(clients.openWindow(event.notification.data.url));
+});
@@ -0,0 +1,297 @@
+// Package stats aggregates study time and task completion into the
+// statistics served by /api/stats.
+package stats
+
+import (
+	"math"
+	"sort"
+	"studybuddy/models"
+	"studybuddy/recurrence"
+	"time"
+)
+
+// dateLayout is the format of the days used as keys in the study log
+const dateLayout = "2006-01-02"
+
+// Sizes of the series returned by Compute
+const (
+	DefaultDays   = 30
+	MaxDays       = 366
+	seriesWeeks   = 12
+	seriesMonths  = 12
+	heatmapDays   = 365
+	secondsPerMin = 60
+)
+
+// Input holds the user data statistics are computed from
+type Input struct {
+	Data       models.AppData
+	Focus      models.FocusData
+	Flashcards models.FlashcardsData
+}
+
+// SecondsByDay splits the interval [start, end) at midnights in loc and
+// returns the seconds spent on each day
+func SecondsByDay(start, end time.Time, loc *time.Location) map[string]int {
+	result := make(map[string]int)
+	start, end = start.In(loc), end.In(loc)
+	for start.Before(end) {
+		next := time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, loc)
+		if next.After(end) {
+			next = end
+		}
+		result[start.Format(dateLayout)] += int(next.Sub(start).Seconds())
+		start = next
+	}
+	return result
+}
+
+// Compute builds the statistics of a user as seen from loc at now, with a
+// daily series covering the last days days
+func Compute(input Input, loc *time.Location, now time.Time, days int) models.StudyStats {
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	minutes, subjects := studyMinutes(input, loc, now)
+
+	result := models.StudyStats{
+		Timezone:  loc.String(),
+		Today:     today.Format(dateLayout),
+		Totals:    totals(minutes, today),
+		Streaks:   streaks(minutes, today),
+		Subjects:  subjects,
+		Reminders: reminders(input.Data.Reminders, today.AddDate(0, 0, -(days-1)), today),
+	}
+
+	for i := days - 1; i >= 0; i-- {
+		day := today.AddDate(0, 0, -i).Format(dateLayout)
+		result.Daily = append(result.Daily, models.DayMinutes{Date: day, Minutes: minutes[day]})
+	}
+
+	week := startOfWeek(today)
+	for i := seriesWeeks - 1; i >= 0; i-- {
+		start := week.AddDate(0, 0, -7*i)
+		result.Weekly = append(result.Weekly, models.PeriodMinutes{
+			Period:  start.Format(dateLayout),
+			Minutes: sumRange(minutes, start, start.AddDate(0, 0, 7)),
+		})
+	}
+
+	month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, loc)
+	for i := seriesMonths - 1; i >= 0; i-- {
+		start := month.AddDate(0, -i, 0)
+		result.Monthly = append(result.Monthly, models.PeriodMinutes{
+			Period:  start.Format("2006-01"),
+			Minutes: sumRange(minutes, start, start.AddDate(0, 1, 0)),
+		})
+	}
+
+	for i := heatmapDays - 1; i >= 0; i-- {
+		day := today.AddDate(0, 0, -i).Format(dateLayout)
+		result.Heatmap = append(result.Heatmap, models.HeatmapDay{
+			Date:    day,
+			Minutes: minutes[day],
+			Level:   heatmapLevel(minutes[day]),
+		})
+	}
+
+	return result
+}
+
+// SegmentEnd returns when the running segment of a focus session, started
+// at start, stops counting: now, or the moment the planned duration ran out
+func SegmentEnd(session models.FocusSession, start, now time.Time) time.Time {
+	remaining := time.Duration(session.PlannedMinutes*60-session.ElapsedSeconds) * time.Second
+	if remaining < 0 {
+		remaining = 0
+	}
+	if limit := start.Add(remaining); now.After(limit) {
+		return limit
+	}
+	return now
+}
+
+// studyMinutes returns the study minutes per day and per subject. Focus
+// sessions are split at midnights in loc, and a running segment counts up to
+// SegmentEnd; flashcard review time is already keyed by the server's day.
+// Days recorded in the study log before the server tracked sessions are
+// taken from the log as they are.
+func studyMinutes(input Input, loc *time.Location, now time.Time) (map[string]int, []models.SubjectStats) {
+	seconds := make(map[string]int)
+	bySubject := make(map[string]*models.SubjectStats)
+	subjectSeconds := make(map[string]int)
+
+	for _, session := range input.Focus.Sessions {
+		stat, ok := bySubject[session.Subject]
+		if !ok {
+			stat = &models.SubjectStats{Subject: session.Subject}
+			bySubject[session.Subject] = stat
+		}
+		stat.Sessions++
+
+		for _, segment := range session.Segments {
+			start, err := time.Parse(time.RFC3339, segment.Start)
+			if err != nil {
+				continue
+			}
+			end := SegmentEnd(session, start, now)
+			if segment.End != "" {
+				if end, err = time.Parse(time.RFC3339, segment.End); err != nil {
+					continue
+				}
+			}
+			for day, value := range SecondsByDay(start, end, loc) {
+				seconds[day] += value
+				subjectSeconds[session.Subject] += value
+			}
+		}
+	}
+
+	for day, value := range input.Flashcards.ReviewSeconds {
+		seconds[day] += value
+	}
+
+	for day, value := range input.Data.StudyLog {
+		_, focused := input.Focus.LoggedSeconds[day]
+		_, reviewed := input.Flashcards.ReviewSeconds[day]
+		if !focused && !reviewed {
+			seconds[day] += value * secondsPerMin
+		}
+	}
+
+	minutes := make(map[string]int, len(seconds))
+	for day, value := range seconds {
+		minutes[day] = value / secondsPerMin
+	}
+
+	subjects := make([]models.SubjectStats, 0, len(bySubject))
+	for name, stat := range bySubject {
+		stat.Minutes = subjectSeconds[name] / secondsPerMin
+		subjects = append(subjects, *stat)
+	}
+	sort.Slice(subjects, func(i, j int) bool {
+		if subjects[i].Minutes != subjects[j].Minutes {
+			return subjects[i].Minutes > subjects[j].Minutes
+		}
+		return subjects[i].Subject < subjects[j].Subject
+	})
+
+	return minutes, subjects
+}
+
+// totals sums the minutes of the current day, week, month and all time
+func totals(minutes map[string]int, today time.Time) models.StudyTotals {
+	result := models.StudyTotals{
+		Today: minutes[today.Format(dateLayout)],
+		Week:  sumRange(minutes, startOfWeek(today), today.AddDate(0, 0, 1)),
+		Month: sumRange(minutes, time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location()), today.AddDate(0, 0, 1)),
+	}
+	for _, value := range minutes {
+		result.AllTime += value
+	}
+	return result
+}
+
+// streaks computes the current and longest runs of days with study time.
+// The current streak is kept while today has no study time yet.
+func streaks(minutes map[string]int, today time.Time) models.StudyStreaks {
+	var result models.StudyStreaks
+
+	days := make([]string, 0, len(minutes))
+	for day, value := range minutes {
+		if value > 0 {
+			days = append(days, day)
+		}
+	}
+	sort.Strings(days)
+
+	run := 0
+	var previous time.Time
+	for _, day := range days {
+		date, err := time.ParseInLocation(dateLayout, day, today.Location())
+		if err != nil {
+			continue
+		}
+		if run > 0 && previous.AddDate(0, 0, 1).Equal(date) {
+			run++
+		} else {
+			run = 1
+		}
+		previous = date
+		if run > result.Longest {
+			result.Longest = run
+		}
+	}
+
+	day := today
+	if minutes[day.Format(dateLayout)] == 0 {
+		day = day.AddDate(0, 0, -1)
+	}
+	for minutes[day.Format(dateLayout)] > 0 {
+		result.Current++
+		day = day.AddDate(0, 0, -1)
+	}
+	return result
+}
+
+// reminders computes the reminder completion rate. Each occurrence of a
+// recurring reminder in [from, to] counts once, completed when its date is
+// in CompletedDates or the whole series is completed.
+func reminders(items []models.Reminder, from, to time.Time) models.ReminderStats {
+	var result models.ReminderStats
+	for _, item := range items {
+		if item.Recurrence == nil {
+			result.Total++
+			if item.Completed {
+				result.Completed++
+			}
+			continue
+		}
+
+		completed := make(map[string]bool, len(item.CompletedDates))
+		for _, date := range item.CompletedDates {
+			completed[date] = true
+		}
+		for _, date := range recurrence.Occurrences(item.Recurrence, item.Date, from, to) {
+			result.Total++
+			if item.Completed || completed[date] {
+				result.Completed++
+			}
+		}
+	}
+	result.Pending = result.Total - result.Completed
+	if result.Total > 0 {
+		result.CompletionRate = math.Round(float64(result.Completed)/float64(result.Total)*100) / 100
+	}
+	return result
+}
+
+// startOfWeek returns the Monday of the week of day
+func startOfWeek(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// sumRange sums the minutes of the days in [start, end)
+func sumRange(minutes map[string]int, start, end time.Time) int {
+	total := 0
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		total += minutes[day.Format(dateLayout)]
+	}
+	return total
+}
+
+// heatmapLevel maps study minutes to an intensity level from 0 to 4
+func heatmapLevel(minutes int) int {
+	switch {
+	case minutes == 0:
+		return 0
+	case minutes < 30:
+		return 1
+	case minutes < 60:
+		return 2
+	case minutes < 120:
+		return 3
+	default:
+		return 4
+	}
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"studybuddy/models"
+)
+
+// ErrNotFound is returned by a Backend when the requested record does not exist
+var ErrNotFound = errors.New("registro não encontrado")
+
+// systemScope is the user ID used for documents that do not belong to a user
+const systemScope int64 = 0
+
+// Backend persists users, application data and materials trees.
+// The storage package serializes calls that touch the same kind of record
+// (users, data, materials, each document) with its own mutexes, so
+// implementations only need to tolerate concurrent calls on different kinds.
+type Backend interface {
+	// LoadUsers returns all users keyed by email
+	LoadUsers() (map[string]models.User, error)
+	// SaveUser inserts or updates a user, matching by ID
+	SaveUser(user models.User) error
+	// DeleteUser removes a user by ID
+	DeleteUser(id int64) error
+
+	// LoadData returns a user's application data or ErrNotFound
+	LoadData(userID int64) (models.AppData, error)
+	// SaveData replaces a user's application data
+	SaveData(userID int64, data models.AppData) error
+
+	// LoadMaterials returns a user's materials tree or ErrNotFound
+	LoadMaterials(userID int64) (*models.MaterialsTree, error)
+	// SaveMaterials replaces a user's materials tree
+	SaveMaterials(userID int64, tree *models.MaterialsTree) error
+
+	// ReadDocument returns a named auxiliary JSON document or ErrNotFound.
+	// Documents with userID 0 are not tied to any user.
+	ReadDocument(userID int64, name string) ([]byte, error)
+	// WriteDocument replaces a named auxiliary JSON document
+	WriteDocument(userID int64, name string, body []byte) error
+	// ListDocuments returns the names of a user's auxiliary documents
+	ListDocuments(userID int64) ([]string, error)
+	// DeleteDocument removes a named auxiliary document, if it exists
+	DeleteDocument(userID int64, name string) error
+
+	// DeleteUserData removes a user's application data, materials and documents
+	DeleteUserData(userID int64) error
+
+	// Close releases the resources held by the backend
+	Close() error
+}
+
+// backend is the Backend used by the package level storage functions
+var backend Backend = NewJSONBackend("storage")
+
+// SetBackend replaces the Backend used by the storage functions.
+// Must be called before LoadUsers and before serving requests.
+func SetBackend(b Backend) {
+	backend = b
+}
+
+// NewBackendFromEnv creates the Backend selected by STORAGE_BACKEND
+// ("json", the default, or "sqlite"). The SQLite database path is read
+// from SQLITE_PATH.
+func NewBackendFromEnv() (Backend, error) {
+	kind := os.Getenv("STORAGE_BACKEND")
+	switch kind {
+	case "", "json":
+		return NewJSONBackend("storage"), nil
+	case "sqlite":
+		path := os.Getenv("SQLITE_PATH")
+		if path == "" {
+			path = "storage/studybuddy.db"
+		}
+		log.Printf("INFO: Using SQLite storage at %s", path)
+		return NewSQLiteBackend(path)
+	default:
+		return nil, fmt.Errorf("backend de armazenamento desconhecido: %s", kind)
+	}
+}
+
+// CopyBackend copies every user together with their data, materials and
+// documents from src into dst
+func CopyBackend(src, dst Backend) error {
+	srcUsers, err := src.LoadUsers()
+	if err != nil {
+		return err
+	}
+
+	if err := copyDocuments(src, dst, systemScope); err != nil {
+		return err
+	}
+
+	for _, user := range srcUsers {
+		if err := dst.SaveUser(user); err != nil {
+			return fmt.Errorf("usuário %s: %w", user.Email, err)
+		}
+
+		data, err := src.LoadData(user.ID)
+		if err == nil {
+			err = dst.SaveData(user.ID, data)
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("dados do usuário %s: %w", user.Email, err)
+		}
+
+		tree, err := src.LoadMaterials(user.ID)
+		if err == nil {
+			err = dst.SaveMaterials(user.ID, tree)
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("materiais do usuário %s: %w", user.Email, err)
+		}
+
+		if err := copyDocuments(src, dst, user.ID); err != nil {
+			return fmt.Errorf("documentos do usuário %s: %w", user.Email, err)
+		}
+
+		log.Printf("INFO: Imported user %s", user.Email)
+	}
+	return nil
+}
+
+// copyDocuments copies all documents of one scope from src into dst
+func copyDocuments(src, dst Backend, userID int64) error {
+	names, err := src.ListDocuments(userID)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		body, err := src.ReadDocument(userID, name)
+		if err != nil {
+			return err
+		}
+		if err := dst.WriteDocument(userID, name, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
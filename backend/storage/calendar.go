@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// calendarFeed is the secret token of a user's calendar feed. Only the
+// SHA-256 hash of the token is stored.
+type calendarFeed struct {
+	UserID    int64     `json:"userId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+var calendarMutex sync.Mutex
+
+// calendarFeedsDocument is the name of the document holding feed tokens
+const calendarFeedsDocument = "calendar_feeds"
+
+// loadCalendarFeeds reads the feed tokens. A read error is returned rather
+// than treated as an empty document, which would then be written back over
+// the feeds of every user. Must be called with calendarMutex held.
+func loadCalendarFeeds() (map[string]calendarFeed, error) {
+	feeds := make(map[string]calendarFeed)
+	if err := loadDocument(systemScope, calendarFeedsDocument, &feeds); err != nil {
+		return nil, err
+	}
+	return feeds, nil
+}
+
+// saveCalendarFeeds writes the feed tokens.
+// Must be called with calendarMutex held.
+func saveCalendarFeeds(feeds map[string]calendarFeed) error {
+	return saveDocument(systemScope, calendarFeedsDocument, feeds)
+}
+
+// removeCalendarFeeds deletes the tokens of a user, reporting whether any existed
+func removeCalendarFeeds(feeds map[string]calendarFeed, userID int64) bool {
+	removed := false
+	for hash, feed := range feeds {
+		if feed.UserID == userID {
+			delete(feeds, hash)
+			removed = true
+		}
+	}
+	return removed
+}
+
+// CreateCalendarToken issues the secret token of a user's calendar feed,
+// invalidating the previous one
+func CreateCalendarToken(userID int64) (string, error) {
+	calendarMutex.Lock()
+	defer calendarMutex.Unlock()
+
+	token, err := generateToken(24)
+	if err != nil {
+		return "", err
+	}
+
+	feeds, err := loadCalendarFeeds()
+	if err != nil {
+		return "", err
+	}
+	removeCalendarFeeds(feeds, userID)
+	feeds[hashToken(token)] = calendarFeed{UserID: userID, CreatedAt: time.Now()}
+
+	if err := saveCalendarFeeds(feeds); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// CalendarFeedEnabled reports whether a user has a calendar feed token
+func CalendarFeedEnabled(userID int64) (bool, error) {
+	calendarMutex.Lock()
+	defer calendarMutex.Unlock()
+
+	feeds, err := loadCalendarFeeds()
+	if err != nil {
+		return false, err
+	}
+	for _, feed := range feeds {
+		if feed.UserID == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RevokeCalendarToken disables a user's calendar feed
+func RevokeCalendarToken(userID int64) error {
+	calendarMutex.Lock()
+	defer calendarMutex.Unlock()
+
+	feeds, err := loadCalendarFeeds()
+	if err != nil {
+		return err
+	}
+	if !removeCalendarFeeds(feeds, userID) {
+		return nil
+	}
+	return saveCalendarFeeds(feeds)
+}
+
+// CalendarTokenUser returns the user a calendar feed token belongs to
+func CalendarTokenUser(token string) (int64, bool, error) {
+	calendarMutex.Lock()
+	defer calendarMutex.Unlock()
+
+	feeds, err := loadCalendarFeeds()
+	if err != nil {
+		return 0, false, err
+	}
+	feed, exists := feeds[hashToken(token)]
+	return feed.UserID, exists, nil
+}
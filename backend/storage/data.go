@@ -1,77 +1,182 @@
 package storage
 
 import (
-	"encoding/json"
+	"errors"
+	"log"
 	"os"
 	"studybuddy/models"
 	"sync"
+	"time"
 )
 
 var (
-	dataFile  = "storage/data.json"
 	dataMutex sync.Mutex
 )
 
-// LoadData loads application data from the JSON file
-func LoadData() (models.AppData, error) {
+// AnyRevision disables the revision check on writes
+const AnyRevision int64 = -1
+
+// ErrRevisionConflict is returned when a write is based on an outdated revision
+var ErrRevisionConflict = errors.New("os dados foram alterados em outra sessão")
+
+// LoadData loads a user's application data from the storage backend
+func LoadData(userID int64) (models.AppData, error) {
 	dataMutex.Lock()
 	defer dataMutex.Unlock()
 
-	bytes, err := os.ReadFile(dataFile)
-	if err != nil {
-		return models.AppData{}, nil // Return empty data if file doesn't exist
+	data, err := backend.LoadData(userID)
+	if errors.Is(err, ErrNotFound) {
+		return models.AppData{}, nil // Return empty data if nothing was saved yet
 	}
+	return data, err
+}
 
-	var data models.AppData
-	if err := json.Unmarshal(bytes, &data); err != nil {
-		return models.AppData{}, err
-	}
-	return data, nil
+// SaveData replaces a user's application data. When expectedRevision is not
+// AnyRevision the write fails with ErrRevisionConflict unless it matches the
+// stored revision. The study log is kept: it is computed by the server from
+// recorded sessions and reviews, never written by the client.
+func SaveData(userID int64, data models.AppData, expectedRevision int64) (models.AppData, error) {
+	return UpdateData(userID, expectedRevision, func(current *models.AppData) error {
+		studyLog := current.StudyLog
+		*current = data
+		current.StudyLog = studyLog
+		return nil
+	})
 }
 
-// SaveData saves application data to the JSON file
-func SaveData(data models.AppData) error {
+// AddStudyMinutes adds minutes per day (YYYY-MM-DD) to a user's study log.
+// The revision is kept: clients never write the study log, so recording a
+// focus session or a review must not make their next save conflict.
+func AddStudyMinutes(userID int64, minutes map[string]int) error {
+	if len(minutes) == 0 {
+		return nil
+	}
+
 	dataMutex.Lock()
 	defer dataMutex.Unlock()
 
-	bytes, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
+	data, err := backend.LoadData(userID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		return err
 	}
-	return os.WriteFile(dataFile, bytes, 0644)
+	if data.StudyLog == nil {
+		data.StudyLog = make(map[string]int)
+	}
+	for day, value := range minutes {
+		data.StudyLog[day] += value
+	}
+	return backend.SaveData(userID, data)
 }
 
-// DeleteEvent deletes an event by ID and returns the updated data
-func DeleteEvent(eventID int64) (models.AppData, error) {
+// UpdateData loads a user's application data, applies fn and saves the
+// result with the next revision, holding the data lock for the whole
+// sequence. Nothing is saved when fn returns an error or when
+// expectedRevision is stale.
+func UpdateData(userID int64, expectedRevision int64, fn func(data *models.AppData) error) (models.AppData, error) {
 	dataMutex.Lock()
 	defer dataMutex.Unlock()
 
-	bytes, err := os.ReadFile(dataFile)
+	return updateData(userID, expectedRevision, fn)
+}
+
+// TrashData is UpdateData for deletions: fn removes an item and returns it
+// as a trash item, which is recorded once the data is saved
+func TrashData(userID int64, expectedRevision int64, fn func(data *models.AppData) (models.TrashItem, error)) (models.AppData, error) {
+	dataMutex.Lock()
+	defer dataMutex.Unlock()
+
+	var item models.TrashItem
+	data, err := updateData(userID, expectedRevision, func(data *models.AppData) (err error) {
+		item, err = fn(data)
+		return err
+	})
 	if err != nil {
 		return models.AppData{}, err
 	}
+	return data, MoveToTrash(userID, item)
+}
 
-	var data models.AppData
-	if err := json.Unmarshal(bytes, &data); err != nil {
+// updateData implements UpdateData. The caller holds dataMutex.
+func updateData(userID int64, expectedRevision int64, fn func(data *models.AppData) error) (models.AppData, error) {
+	data, err := backend.LoadData(userID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		return models.AppData{}, err
 	}
 
-	newEvents := make([]models.Event, 0)
-	for _, event := range data.Events {
-		if event.ID != eventID {
-			newEvents = append(newEvents, event)
-		}
+	revision := data.Revision
+	if expectedRevision != AnyRevision && expectedRevision != revision {
+		return models.AppData{}, ErrRevisionConflict
 	}
 
-	data.Events = newEvents
-
-	updatedBytes, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
+	// fn may edit notes in place, so the previous versions are copied
+	notes := append([]models.Note(nil), data.Notes...)
+	if err := fn(&data); err != nil {
 		return models.AppData{}, err
 	}
-	if err := os.WriteFile(dataFile, updatedBytes, 0644); err != nil {
+	data.Revision = revision + 1
+
+	if err := backend.SaveData(userID, data); err != nil {
 		return models.AppData{}, err
 	}
-
+	if err := recordNoteHistory(userID, notes, data.Notes); err != nil {
+		log.Printf("ERROR: Could not record note history of user %d: %v", userID, err)
+	}
 	return data, nil
 }
+
+// NextID returns a new item ID that is not in ids. IDs are based on the
+// current time in milliseconds, matching the IDs generated by the frontend.
+func NextID(ids []int64) int64 {
+	id := time.Now().UnixMilli()
+	for _, existing := range ids {
+		if existing >= id {
+			id = existing + 1
+		}
+	}
+	return id
+}
+
+// DeleteEvent moves an event to the trash and returns the updated data
+func DeleteEvent(userID int64, expectedRevision int64, eventID int64) (models.AppData, error) {
+	return TrashData(userID, expectedRevision, func(data *models.AppData) (models.TrashItem, error) {
+		newEvents := make([]models.Event, 0)
+		var deleted *models.Event
+		for _, event := range data.Events {
+			if event.ID != eventID {
+				newEvents = append(newEvents, event)
+			} else {
+				deleted = &event
+			}
+		}
+		if deleted == nil {
+			return models.TrashItem{}, ErrNotFound
+		}
+
+		data.Events = newEvents
+		return models.TrashItem{Kind: models.TrashEvent, Name: deleted.Title, Event: deleted}, nil
+	})
+}
+
+// DeleteUserData removes all of a user's data, materials and uploaded files
+func DeleteUserData(userID int64) error {
+	dataMutex.Lock()
+	defer dataMutex.Unlock()
+	materialsMutex.Lock()
+	defer materialsMutex.Unlock()
+
+	if err := backend.DeleteUserData(userID); err != nil {
+		return err
+	}
+	forgetSessions(userID)
+	delete(materialIndex, userID)
+	if err := RevokeCalendarToken(userID); err != nil {
+		return err
+	}
+	if err := removeShareLinks(userID); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(userThumbnailsDir(userID)); err != nil {
+		return err
+	}
+	return os.RemoveAll(UserUploadsDir(userID))
+}
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// loadDocument decodes a user's document into v. A missing document leaves
+// v untouched.
+func loadDocument(userID int64, name string, v interface{}) error {
+	bytes, err := backend.ReadDocument(userID, name)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, v)
+}
+
+// saveDocument encodes v and stores it as a user's document
+func saveDocument(userID int64, name string, v interface{}) error {
+	bytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return backend.WriteDocument(userID, name, bytes)
+}
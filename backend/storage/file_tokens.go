@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Lifetimes of signed file URLs. Media URLs last long enough for a lecture,
+// as a player cannot refresh its URL while it is playing.
+const (
+	FileTokenTTL  = 15 * time.Minute
+	MediaTokenTTL = 6 * time.Hour
+)
+
+// FileToken describes the access a signed file URL grants
+type FileToken struct {
+	UserID     int64
+	SessionID  string
+	MaterialID string
+	Version    int    // Previous file version, or 0 for the current file
+	Download   bool   // Sent as an attachment instead of inline
+	LinkID     string // Public link the URL was issued through, instead of a session
+}
+
+// fileClaims are the claims of a signed file URL
+type fileClaims struct {
+	jwt.RegisteredClaims
+	MaterialID string `json:"mid"`
+	Version    int    `json:"ver,omitempty"`
+	Download   bool   `json:"dl,omitempty"`
+	LinkID     string `json:"lnk,omitempty"`
+}
+
+// LinkAccessTTL is how long a public link stays open after its password
+// was entered
+const LinkAccessTTL = time.Hour
+
+// linkAccessClaims are the claims of the token returned when a public
+// link with a password is opened
+type linkAccessClaims struct {
+	jwt.RegisteredClaims
+	LinkID string `json:"lnk"`
+}
+
+// fileTokenKey derives the HMAC key file tokens are signed with, so that
+// they cannot be used as access tokens
+func fileTokenKey() []byte {
+	return derivedKey("files")
+}
+
+// derivedKey derives an HMAC key for a kind of token from the JWT secret
+func derivedKey(purpose string) []byte {
+	mac := hmac.New(sha256.New, jwtSecret)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// GenerateFileToken signs a token granting access to one material of a
+// session until it expires, for <img>, <video> and links that cannot send
+// the Authorization header
+func GenerateFileToken(token FileToken, ttl time.Duration) (string, time.Time, error) {
+	expiresAt := time.Now().Add(ttl)
+	claims := &fileClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        token.SessionID,
+			Subject:   fmt.Sprintf("%d", token.UserID),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		MaterialID: token.MaterialID,
+		Version:    token.Version,
+		Download:   token.Download,
+		LinkID:     token.LinkID,
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(fileTokenKey())
+	return signed, expiresAt, err
+}
+
+// ParseFileToken verifies the signature and expiry of a file token
+func ParseFileToken(tokenString string) (FileToken, error) {
+	claims := &fileClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("método de assinatura inesperado: %v", token.Header["alg"])
+		}
+		return fileTokenKey(), nil
+	})
+	if err != nil {
+		return FileToken{}, err
+	}
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return FileToken{}, err
+	}
+	return FileToken{
+		UserID:     userID,
+		SessionID:  claims.ID,
+		MaterialID: claims.MaterialID,
+		Version:    claims.Version,
+		Download:   claims.Download,
+		LinkID:     claims.LinkID,
+	}, nil
+}
+
+// GenerateLinkAccessToken signs a token proving that the password of a
+// public link was entered, so it is not checked again for every file
+func GenerateLinkAccessToken(linkID string) (string, time.Time, error) {
+	expiresAt := time.Now().Add(LinkAccessTTL)
+	claims := &linkAccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		LinkID: linkID,
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(derivedKey("links"))
+	return signed, expiresAt, err
+}
+
+// ParseLinkAccessToken verifies a link access token and returns its link ID
+func ParseLinkAccessToken(tokenString string) (string, error) {
+	claims := &linkAccessClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("método de assinatura inesperado: %v", token.Header["alg"])
+		}
+		return derivedKey("links"), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return claims.LinkID, nil
+}
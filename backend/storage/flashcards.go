@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"studybuddy/models"
+	"sync"
+)
+
+// flashcardsDocument is the name of the per-user document holding decks and cards
+const flashcardsDocument = "flashcards"
+
+var flashcardsMutex sync.Mutex
+
+// LoadFlashcards loads a user's decks and cards
+func LoadFlashcards(userID int64) (models.FlashcardsData, error) {
+	flashcardsMutex.Lock()
+	defer flashcardsMutex.Unlock()
+
+	var data models.FlashcardsData
+	err := loadDocument(userID, flashcardsDocument, &data)
+	return data, err
+}
+
+// UpdateFlashcards loads a user's decks and cards, applies fn and saves the
+// result, holding the flashcards lock for the whole sequence. Nothing is
+// saved when fn returns an error.
+func UpdateFlashcards(userID int64, fn func(data *models.FlashcardsData) error) (models.FlashcardsData, error) {
+	flashcardsMutex.Lock()
+	defer flashcardsMutex.Unlock()
+
+	var data models.FlashcardsData
+	if err := loadDocument(userID, flashcardsDocument, &data); err != nil {
+		return models.FlashcardsData{}, err
+	}
+
+	if err := fn(&data); err != nil {
+		return models.FlashcardsData{}, err
+	}
+
+	if err := saveDocument(userID, flashcardsDocument, data); err != nil {
+		return models.FlashcardsData{}, err
+	}
+	return data, nil
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"studybuddy/models"
+	"sync"
+)
+
+// focusDocument is the name of the per-user document holding focus sessions
+const focusDocument = "focus"
+
+var focusMutex sync.Mutex
+
+// LoadFocus loads a user's focus sessions
+func LoadFocus(userID int64) (models.FocusData, error) {
+	focusMutex.Lock()
+	defer focusMutex.Unlock()
+
+	var data models.FocusData
+	err := loadDocument(userID, focusDocument, &data)
+	return data, err
+}
+
+// UpdateFocus loads a user's focus sessions, applies fn and saves the
+// result, holding the focus lock for the whole sequence. Nothing is saved
+// when fn returns an error.
+func UpdateFocus(userID int64, fn func(data *models.FocusData) error) (models.FocusData, error) {
+	focusMutex.Lock()
+	defer focusMutex.Unlock()
+
+	var data models.FocusData
+	if err := loadDocument(userID, focusDocument, &data); err != nil {
+		return models.FocusData{}, err
+	}
+
+	if err := fn(&data); err != nil {
+		return models.FocusData{}, err
+	}
+
+	if err := saveDocument(userID, focusDocument, data); err != nil {
+		return models.FocusData{}, err
+	}
+	return data, nil
+}
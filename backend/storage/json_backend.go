@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"studybuddy/models"
+)
+
+// JSONBackend stores everything as JSON files below a base directory:
+// users.json, userdata/<id>/data.json, userdata/<id>/materials.json and
+// one <name>.json file per document
+type JSONBackend struct {
+	baseDir string
+	users   map[string]models.User
+}
+
+// NewJSONBackend creates a JSONBackend rooted at baseDir
+func NewJSONBackend(baseDir string) *JSONBackend {
+	return &JSONBackend{
+		baseDir: baseDir,
+		users:   make(map[string]models.User),
+	}
+}
+
+// Reserved file names that are not auxiliary documents
+var jsonReservedNames = map[string]bool{
+	"users":     true,
+	"data":      true,
+	"materials": true,
+}
+
+func (b *JSONBackend) usersFile() string {
+	return filepath.Join(b.baseDir, "users.json")
+}
+
+func (b *JSONBackend) userDir(userID int64) string {
+	if userID == systemScope {
+		return b.baseDir
+	}
+	return filepath.Join(b.baseDir, "userdata", fmt.Sprintf("%d", userID))
+}
+
+func (b *JSONBackend) documentFile(userID int64, name string) string {
+	return filepath.Join(b.userDir(userID), name+".json")
+}
+
+// readFile reads a file, translating a missing file into ErrNotFound
+func (b *JSONBackend) readFile(path string) ([]byte, error) {
+	bytes, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return bytes, err
+}
+
+// writeFile replaces a file atomically, creating its directory if needed
+func (b *JSONBackend) writeFile(path string, bytes []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, bytes, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// writeJSON serializes v and writes it to path
+func (b *JSONBackend) writeJSON(path string, v interface{}) error {
+	bytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return b.writeFile(path, bytes)
+}
+
+// LoadUsers loads users from users.json
+func (b *JSONBackend) LoadUsers() (map[string]models.User, error) {
+	bytes, err := b.readFile(b.usersFile())
+	if err == ErrNotFound {
+		return make(map[string]models.User), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make(map[string]models.User)
+	if err := json.Unmarshal(bytes, &loaded); err != nil {
+		return nil, err
+	}
+
+	b.users = make(map[string]models.User, len(loaded))
+	for email, user := range loaded {
+		b.users[email] = user
+	}
+	return loaded, nil
+}
+
+// SaveUser stores a user and rewrites users.json
+func (b *JSONBackend) SaveUser(user models.User) error {
+	for email, existing := range b.users {
+		if existing.ID == user.ID {
+			delete(b.users, email)
+		}
+	}
+	b.users[user.Email] = user
+	return b.writeJSON(b.usersFile(), b.users)
+}
+
+// DeleteUser removes a user and rewrites users.json
+func (b *JSONBackend) DeleteUser(id int64) error {
+	for email, existing := range b.users {
+		if existing.ID == id {
+			delete(b.users, email)
+		}
+	}
+	return b.writeJSON(b.usersFile(), b.users)
+}
+
+// LoadData loads a user's data.json
+func (b *JSONBackend) LoadData(userID int64) (models.AppData, error) {
+	bytes, err := b.readFile(b.documentFile(userID, "data"))
+	if err != nil {
+		return models.AppData{}, err
+	}
+
+	var data models.AppData
+	if err := json.Unmarshal(bytes, &data); err != nil {
+		return models.AppData{}, err
+	}
+	return data, nil
+}
+
+// SaveData rewrites a user's data.json
+func (b *JSONBackend) SaveData(userID int64, data models.AppData) error {
+	return b.writeJSON(b.documentFile(userID, "data"), data)
+}
+
+// LoadMaterials loads a user's materials.json
+func (b *JSONBackend) LoadMaterials(userID int64) (*models.MaterialsTree, error) {
+	bytes, err := b.readFile(b.documentFile(userID, "materials"))
+	if err != nil {
+		return nil, err
+	}
+
+	var tree models.MaterialsTree
+	if err := json.Unmarshal(bytes, &tree); err != nil {
+		return nil, err
+	}
+	return &tree, nil
+}
+
+// SaveMaterials rewrites a user's materials.json
+func (b *JSONBackend) SaveMaterials(userID int64, tree *models.MaterialsTree) error {
+	return b.writeJSON(b.documentFile(userID, "materials"), tree)
+}
+
+// ReadDocument reads <name>.json from the user's directory
+func (b *JSONBackend) ReadDocument(userID int64, name string) ([]byte, error) {
+	return b.readFile(b.documentFile(userID, name))
+}
+
+// WriteDocument rewrites <name>.json in the user's directory
+func (b *JSONBackend) WriteDocument(userID int64, name string, body []byte) error {
+	return b.writeFile(b.documentFile(userID, name), body)
+}
+
+// DeleteDocument removes <name>.json from the user's directory
+func (b *JSONBackend) DeleteDocument(userID int64, name string) error {
+	err := os.Remove(b.documentFile(userID, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// ListDocuments lists the .json documents in the user's directory
+func (b *JSONBackend) ListDocuments(userID int64) ([]string, error) {
+	entries, err := os.ReadDir(b.userDir(userID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if !jsonReservedNames[name] {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// DeleteUserData removes the user's directory
+func (b *JSONBackend) DeleteUserData(userID int64) error {
+	if userID == systemScope {
+		return fmt.Errorf("escopo inválido")
+	}
+	return os.RemoveAll(b.userDir(userID))
+}
+
+// Close is a no-op for the JSON backend
+func (b *JSONBackend) Close() error {
+	return nil
+}
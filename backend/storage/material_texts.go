@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"studybuddy/models"
+	"sync"
+)
+
+// materialTextsDocument is the name of the per-user document holding the
+// extraction status of uploaded materials. Each text is kept in its own
+// document, so an extraction or a search does not rewrite or read them all.
+const materialTextsDocument = "material_texts"
+
+// materialTextPrefix prefixes the name of the document holding the text of
+// one material
+const materialTextPrefix = "material_text_"
+
+var materialTextsMutex sync.Mutex
+
+// materialTextBody is the document holding the text of one material
+type materialTextBody struct {
+	Text string `json:"text"`
+}
+
+// LoadMaterialTexts loads the extraction status of a user's materials. The
+// texts themselves are read with LoadMaterialText.
+func LoadMaterialTexts(userID int64) (models.MaterialTextsData, error) {
+	materialTextsMutex.Lock()
+	defer materialTextsMutex.Unlock()
+
+	return loadMaterialTexts(userID)
+}
+
+// LoadMaterialText loads the text extracted from one material, empty when
+// there is none
+func LoadMaterialText(userID int64, nodeID string) (string, error) {
+	materialTextsMutex.Lock()
+	defer materialTextsMutex.Unlock()
+
+	name, err := materialTextName(nodeID)
+	if err != nil {
+		return "", err
+	}
+	var body materialTextBody
+	if err := loadDocument(userID, name, &body); err != nil {
+		return "", err
+	}
+	return body.Text, nil
+}
+
+// UpdateMaterialTexts loads a user's extraction status, applies fn and saves
+// the result with a new revision, holding the lock for the whole sequence.
+// Entries set by fn are stored with their Text; the texts of removed entries
+// are deleted. Nothing is saved when fn returns an error.
+func UpdateMaterialTexts(userID int64, fn func(data *models.MaterialTextsData) error) (models.MaterialTextsData, error) {
+	materialTextsMutex.Lock()
+	defer materialTextsMutex.Unlock()
+
+	data, err := loadMaterialTexts(userID)
+	if err != nil {
+		return models.MaterialTextsData{}, err
+	}
+	loaded := make(map[string]models.MaterialText, len(data.Texts))
+	for id, text := range data.Texts {
+		loaded[id] = text
+	}
+
+	if err := fn(&data); err != nil {
+		return models.MaterialTextsData{}, err
+	}
+
+	data.Revision++
+	if err := saveMaterialTexts(userID, &data, loaded); err != nil {
+		return models.MaterialTextsData{}, err
+	}
+	return data, nil
+}
+
+// loadMaterialTexts loads the status document. Documents written before the
+// texts were split out still embed them and are migrated on the first load.
+func loadMaterialTexts(userID int64) (models.MaterialTextsData, error) {
+	var data models.MaterialTextsData
+	if err := loadDocument(userID, materialTextsDocument, &data); err != nil {
+		return models.MaterialTextsData{}, err
+	}
+	if data.Texts == nil {
+		data.Texts = make(map[string]models.MaterialText)
+	}
+
+	for _, text := range data.Texts {
+		if text.Text != "" {
+			if err := saveMaterialTexts(userID, &data, nil); err != nil {
+				return models.MaterialTextsData{}, err
+			}
+			break
+		}
+	}
+	return data, nil
+}
+
+// saveMaterialTexts writes the texts of the entries that differ from loaded,
+// then the status document without texts, and finally deletes the texts of
+// the entries no longer present
+func saveMaterialTexts(userID int64, data *models.MaterialTextsData, loaded map[string]models.MaterialText) error {
+	for id, text := range data.Texts {
+		if previous, ok := loaded[id]; ok && previous == text {
+			continue
+		}
+		name, err := materialTextName(id)
+		if err != nil {
+			return err
+		}
+		if text.Text == "" {
+			err = backend.DeleteDocument(userID, name)
+		} else {
+			err = saveDocument(userID, name, materialTextBody{Text: text.Text})
+		}
+		if err != nil {
+			return err
+		}
+		text.Text = ""
+		data.Texts[id] = text
+	}
+
+	if err := saveDocument(userID, materialTextsDocument, data); err != nil {
+		return err
+	}
+
+	for id := range loaded {
+		if _, ok := data.Texts[id]; ok {
+			continue
+		}
+		name, err := materialTextName(id)
+		if err != nil {
+			return err
+		}
+		if err := backend.DeleteDocument(userID, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// materialTextName returns the name of the document holding the text of a
+// material, rejecting IDs that could escape the user's documents
+func materialTextName(nodeID string) (string, error) {
+	if nodeID == "" || strings.ContainsAny(nodeID, `/\.`) {
+		return "", errors.New("identificador de material inválido")
+	}
+	return materialTextPrefix + nodeID, nil
+}
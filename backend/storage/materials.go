@@ -1,39 +1,40 @@
 package storage
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"path/filepath"
 	"studybuddy/models"
 	"sync"
 	"time"
 )
 
 var (
-	materialsFile  = "storage/materials.json"
+	uploadsRoot    = "storage/uploads"
 	materialsMutex sync.Mutex
+
+	// materialIndex caches each user's materials by ID, without children, so
+	// that file requests such as media range requests do not load the whole
+	// tree. It is guarded by materialsMutex and dropped on every write.
+	materialIndex = make(map[int64]map[string]models.MaterialNode)
 )
 
-// LoadMaterials loads the materials tree from the JSON file
-func LoadMaterials() (*models.MaterialsTree, error) {
+// UserUploadsDir returns the directory where a user's uploaded files are stored
+func UserUploadsDir(userID int64) string {
+	return filepath.Join(uploadsRoot, fmt.Sprintf("%d", userID))
+}
+
+// LoadMaterials loads a user's materials tree from the storage backend
+func LoadMaterials(userID int64) (*models.MaterialsTree, error) {
 	materialsMutex.Lock()
 	defer materialsMutex.Unlock()
 
-	bytes, err := os.ReadFile(materialsFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			// Return default tree if file doesn't exist
-			return createDefaultTree(), nil
-		}
-		return nil, err
-	}
-
-	var tree models.MaterialsTree
-	if err := json.Unmarshal(bytes, &tree); err != nil {
-		return nil, err
+	tree, err := backend.LoadMaterials(userID)
+	if errors.Is(err, ErrNotFound) {
+		// Return default tree if nothing was saved yet
+		return createDefaultTree(), nil
 	}
-	return &tree, nil
+	return tree, err
 }
 
 // createDefaultTree creates a default empty materials tree
@@ -48,16 +49,105 @@ func createDefaultTree() *models.MaterialsTree {
 	}
 }
 
-// SaveMaterials saves the materials tree to the JSON file
-func SaveMaterials(tree *models.MaterialsTree) error {
+// UpdateMaterials loads a user's materials tree, applies fn and saves the
+// result with the next revision, holding the materials lock for the whole
+// sequence. Nothing is saved when fn returns an error or when
+// expectedRevision is stale.
+func UpdateMaterials(userID int64, expectedRevision int64, fn func(tree *models.MaterialsTree) error) (*models.MaterialsTree, error) {
 	materialsMutex.Lock()
 	defer materialsMutex.Unlock()
 
-	bytes, err := json.MarshalIndent(tree, "", "  ")
-	if err != nil {
+	return updateMaterials(userID, expectedRevision, fn)
+}
+
+// TrashMaterials is UpdateMaterials for deletions: fn removes a node and
+// returns it as a trash item, which is recorded once the tree is saved. The
+// lock is held until then, so the files of the node stay referenced.
+func TrashMaterials(userID int64, expectedRevision int64, fn func(tree *models.MaterialsTree) (models.TrashItem, error)) (*models.MaterialsTree, error) {
+	materialsMutex.Lock()
+	defer materialsMutex.Unlock()
+
+	var item models.TrashItem
+	tree, err := updateMaterials(userID, expectedRevision, func(tree *models.MaterialsTree) (err error) {
+		item, err = fn(tree)
 		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tree, MoveToTrash(userID, item)
+}
+
+// updateMaterials implements UpdateMaterials. The caller holds materialsMutex.
+func updateMaterials(userID int64, expectedRevision int64, fn func(tree *models.MaterialsTree) error) (*models.MaterialsTree, error) {
+	tree, err := backend.LoadMaterials(userID)
+	if errors.Is(err, ErrNotFound) {
+		tree = createDefaultTree()
+	} else if err != nil {
+		return nil, err
+	}
+
+	revision := tree.Revision
+	if expectedRevision != AnyRevision && expectedRevision != revision {
+		return nil, ErrRevisionConflict
+	}
+
+	if err := fn(tree); err != nil {
+		return nil, err
+	}
+	tree.Revision = revision + 1
+
+	delete(materialIndex, userID)
+	if err := backend.SaveMaterials(userID, tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// FindMaterial returns a copy of a user's material or folder by ID, without
+// its children. It is served from an in-memory index that is rebuilt after
+// the tree changes.
+func FindMaterial(userID int64, id string) (models.MaterialNode, bool, error) {
+	materialsMutex.Lock()
+	defer materialsMutex.Unlock()
+
+	index, err := userMaterialIndex(userID)
+	if err != nil {
+		return models.MaterialNode{}, false, err
+	}
+	node, ok := index[id]
+	return node, ok, nil
+}
+
+// userMaterialIndex returns the index of a user's materials, building it
+// when needed. The caller holds materialsMutex.
+func userMaterialIndex(userID int64) (map[string]models.MaterialNode, error) {
+	if index, ok := materialIndex[userID]; ok {
+		return index, nil
+	}
+	tree, err := backend.LoadMaterials(userID)
+	if errors.Is(err, ErrNotFound) {
+		tree = createDefaultTree()
+	} else if err != nil {
+		return nil, err
+	}
+	index := make(map[string]models.MaterialNode)
+	indexMaterials(index, tree.Root)
+	materialIndex[userID] = index
+	return index, nil
+}
+
+// indexMaterials adds node and its descendants to index
+func indexMaterials(index map[string]models.MaterialNode, node *models.MaterialNode) {
+	if node == nil {
+		return
+	}
+	entry := *node
+	entry.Children = nil
+	index[node.ID] = entry
+	for _, child := range node.Children {
+		indexMaterials(index, child)
 	}
-	return os.WriteFile(materialsFile, bytes, 0644)
 }
 
 // FindNodeByID finds a node in the tree by its ID
@@ -116,10 +206,6 @@ func AddFolder(tree *models.MaterialsTree, name string, parentID string) (*model
 	}
 
 	parent.Children = append(parent.Children, newFolder)
-
-	if err := SaveMaterials(tree); err != nil {
-		return nil, err
-	}
 	return newFolder, nil
 }
 
@@ -154,10 +240,6 @@ func AddMaterialWithFile(tree *models.MaterialsTree, name, parentID, materialTyp
 	}
 
 	parent.Children = append(parent.Children, newMaterial)
-
-	if err := SaveMaterials(tree); err != nil {
-		return nil, err
-	}
 	return newMaterial, nil
 }
 
@@ -181,7 +263,7 @@ func DeleteNode(tree *models.MaterialsTree, nodeID string) error {
 	}
 	parent.Children = newChildren
 
-	return SaveMaterials(tree)
+	return nil
 }
 
 // MoveNode moves a node to a new parent
@@ -228,7 +310,7 @@ func MoveNode(tree *models.MaterialsTree, nodeID string, newParentID string) err
 	node.ParentID = newParentID
 	newParent.Children = append(newParent.Children, node)
 
-	return SaveMaterials(tree)
+	return nil
 }
 
 // UpdateNode updates a node's properties
@@ -253,5 +335,56 @@ func UpdateNode(tree *models.MaterialsTree, nodeID string, name, materialType, u
 		}
 	}
 
-	return SaveMaterials(tree)
+	return nil
+}
+
+// maxFileVersions is how many previous files are kept per material
+const maxFileVersions = 10
+
+// AddFileVersion replaces the file of a material, keeping the current file
+// as a previous version. The oldest versions beyond maxFileVersions are
+// dropped and their paths returned, to be released once the tree is saved.
+func AddFileVersion(node *models.MaterialNode, filePath, fileName string, fileSize int64, contentType string) ([]string, error) {
+	if node.Type != "material" || !node.IsFile {
+		return nil, errors.New("este material não é um arquivo")
+	}
+
+	current := max(node.Version, 1)
+	latest := current
+	for _, version := range node.Versions {
+		latest = max(latest, version.Version)
+	}
+	node.Versions = append(node.Versions, models.FileVersion{
+		Version:     current,
+		FilePath:    node.FilePath,
+		FileName:    node.FileName,
+		FileSize:    node.FileSize,
+		ContentType: node.ContentType,
+		ReplacedAt:  time.Now().Format(time.RFC3339),
+	})
+
+	var dropped []string
+	if len(node.Versions) > maxFileVersions {
+		for _, version := range node.Versions[:len(node.Versions)-maxFileVersions] {
+			dropped = append(dropped, version.FilePath)
+		}
+		node.Versions = node.Versions[len(node.Versions)-maxFileVersions:]
+	}
+
+	node.Version = latest + 1
+	node.FilePath = filePath
+	node.FileName = fileName
+	node.FileSize = fileSize
+	node.ContentType = contentType
+	return dropped, nil
+}
+
+// FindFileVersion returns a previous file version of a material, or nil
+func FindFileVersion(node *models.MaterialNode, version int) *models.FileVersion {
+	for i := range node.Versions {
+		if node.Versions[i].Version == version {
+			return &node.Versions[i]
+		}
+	}
+	return nil
 }
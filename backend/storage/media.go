@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"studybuddy/models"
+	"sync"
+)
+
+// mediaProgressDocument is the name of the per-user document holding
+// playback positions
+const mediaProgressDocument = "media_progress"
+
+var mediaProgressMutex sync.Mutex
+
+// LoadMediaProgress loads a user's playback positions
+func LoadMediaProgress(userID int64) (models.MediaProgressData, error) {
+	mediaProgressMutex.Lock()
+	defer mediaProgressMutex.Unlock()
+
+	return loadMediaProgress(userID)
+}
+
+// UpdateMediaProgress loads a user's playback positions, applies fn and
+// saves the result, holding the lock for the whole sequence. Nothing is
+// saved when fn returns an error.
+func UpdateMediaProgress(userID int64, fn func(data *models.MediaProgressData) error) (models.MediaProgressData, error) {
+	mediaProgressMutex.Lock()
+	defer mediaProgressMutex.Unlock()
+
+	data, err := loadMediaProgress(userID)
+	if err != nil {
+		return models.MediaProgressData{}, err
+	}
+
+	if err := fn(&data); err != nil {
+		return models.MediaProgressData{}, err
+	}
+
+	if err := saveDocument(userID, mediaProgressDocument, data); err != nil {
+		return models.MediaProgressData{}, err
+	}
+	return data, nil
+}
+
+func loadMediaProgress(userID int64) (models.MediaProgressData, error) {
+	var data models.MediaProgressData
+	if err := loadDocument(userID, mediaProgressDocument, &data); err != nil {
+		return models.MediaProgressData{}, err
+	}
+	if data.Positions == nil {
+		data.Positions = make(map[string]models.MediaPosition)
+	}
+	return data, nil
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"studybuddy/models"
+)
+
+// Files used before data was isolated per user
+var (
+	legacyDataFile      = "storage/data.json"
+	legacyMaterialsFile = "storage/materials.json"
+)
+
+// MigrateLegacyData assigns the shared data.json and materials.json files
+// (and the uploads they reference) to the user with the given email.
+// The legacy files are renamed with a ".migrated" suffix so the migration
+// only runs once.
+func MigrateLegacyData(ownerEmail string) error {
+	owner, exists := GetUser(ownerEmail)
+	if !exists {
+		return fmt.Errorf("usuário %s não encontrado", ownerEmail)
+	}
+
+	if err := migrateLegacyData(owner.ID); err != nil {
+		return err
+	}
+	return migrateLegacyMaterials(owner.ID)
+}
+
+// migrateLegacyData moves the shared application data to the owner
+func migrateLegacyData(ownerID int64) error {
+	dataMutex.Lock()
+	defer dataMutex.Unlock()
+
+	bytes, err := os.ReadFile(legacyDataFile)
+	if os.IsNotExist(err) {
+		log.Printf("INFO: No legacy data file found at %s, skipping", legacyDataFile)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := backend.LoadData(ownerID); !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("o usuário %d já possui dados próprios", ownerID)
+	}
+
+	var data models.AppData
+	if err := json.Unmarshal(bytes, &data); err != nil {
+		return err
+	}
+	if err := backend.SaveData(ownerID, data); err != nil {
+		return err
+	}
+	log.Printf("INFO: Migrated %s to user %d", legacyDataFile, ownerID)
+	return os.Rename(legacyDataFile, legacyDataFile+".migrated")
+}
+
+// migrateLegacyMaterials moves the shared materials tree and its uploaded
+// files to the owner
+func migrateLegacyMaterials(ownerID int64) error {
+	materialsMutex.Lock()
+	defer materialsMutex.Unlock()
+
+	bytes, err := os.ReadFile(legacyMaterialsFile)
+	if os.IsNotExist(err) {
+		log.Printf("INFO: No legacy materials file found at %s, skipping", legacyMaterialsFile)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := backend.LoadMaterials(ownerID); !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("o usuário %d já possui materiais próprios", ownerID)
+	}
+
+	var tree models.MaterialsTree
+	if err := json.Unmarshal(bytes, &tree); err != nil {
+		return err
+	}
+
+	if err := migrateUploads(tree.Root, UserUploadsDir(ownerID)); err != nil {
+		return err
+	}
+
+	delete(materialIndex, ownerID)
+	if err := backend.SaveMaterials(ownerID, &tree); err != nil {
+		return err
+	}
+	log.Printf("INFO: Migrated %s to user %d", legacyMaterialsFile, ownerID)
+	return os.Rename(legacyMaterialsFile, legacyMaterialsFile+".migrated")
+}
+
+// migrateUploads moves files stored directly in the shared uploads folder
+// into the owner's uploads folder and updates the nodes' file paths
+func migrateUploads(node *models.MaterialNode, ownerUploads string) error {
+	if node == nil {
+		return nil
+	}
+
+	if node.IsFile && node.FilePath != "" {
+		cleanPath := filepath.Clean(node.FilePath)
+		if filepath.Dir(cleanPath) == filepath.Clean(uploadsRoot) {
+			if err := os.MkdirAll(ownerUploads, 0755); err != nil {
+				return err
+			}
+			newPath := filepath.Join(ownerUploads, filepath.Base(cleanPath))
+			if err := os.Rename(cleanPath, newPath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			node.FilePath = newPath
+		} else if !strings.HasPrefix(cleanPath, ownerUploads) {
+			log.Printf("WARNING: Material %s references a file outside the uploads folder: %s", node.ID, node.FilePath)
+		}
+	}
+
+	for _, child := range node.Children {
+		if err := migrateUploads(child, ownerUploads); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportJSON copies users, application data, materials and documents from
+// the JSON files below dir into the configured storage backend
+func ImportJSON(dir string) error {
+	if _, ok := backend.(*JSONBackend); ok {
+		return errors.New("o backend configurado já é JSON; selecione outro backend com STORAGE_BACKEND")
+	}
+
+	src := NewJSONBackend(dir)
+	defer src.Close()
+	return CopyBackend(src, backend)
+}
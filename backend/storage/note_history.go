@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"studybuddy/models"
+	"sync"
+	"time"
+)
+
+// noteHistoryDocument is the name of the per-user document holding the
+// previous versions of notes
+const noteHistoryDocument = "note_history"
+
+// maxNoteRevisions is how many previous versions are kept per note
+const maxNoteRevisions = 50
+
+var noteHistoryMutex sync.Mutex
+
+// LoadNoteHistory returns the previous versions of a note, oldest first
+func LoadNoteHistory(userID int64, noteID int64) ([]models.NoteRevision, error) {
+	noteHistoryMutex.Lock()
+	defer noteHistoryMutex.Unlock()
+
+	data, err := loadNoteHistory(userID)
+	if err != nil {
+		return nil, err
+	}
+	revisions := data.Notes[noteID]
+	if revisions == nil {
+		revisions = []models.NoteRevision{}
+	}
+	return revisions, nil
+}
+
+// recordNoteHistory keeps the previous version of every note whose title,
+// content or subject changed between before and after. The caller holds
+// dataMutex.
+func recordNoteHistory(userID int64, before, after []models.Note) error {
+	previous := make(map[int64]models.Note, len(before))
+	for _, note := range before {
+		previous[note.ID] = note
+	}
+	var changed []models.Note
+	for _, note := range after {
+		old, ok := previous[note.ID]
+		if ok && (old.Title != note.Title || old.Content != note.Content || old.Subject != note.Subject) {
+			changed = append(changed, old)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	noteHistoryMutex.Lock()
+	defer noteHistoryMutex.Unlock()
+
+	data, err := loadNoteHistory(userID)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Format(time.RFC3339)
+	for _, note := range changed {
+		revisions := data.Notes[note.ID]
+		number := 1
+		if len(revisions) > 0 {
+			number = revisions[len(revisions)-1].Revision + 1
+		}
+		revisions = append(revisions, models.NoteRevision{
+			Revision:   number,
+			Title:      note.Title,
+			Content:    note.Content,
+			Subject:    note.Subject,
+			ReplacedAt: now,
+		})
+		if len(revisions) > maxNoteRevisions {
+			revisions = revisions[len(revisions)-maxNoteRevisions:]
+		}
+		data.Notes[note.ID] = revisions
+	}
+	return saveDocument(userID, noteHistoryDocument, data)
+}
+
+// forgetNoteHistory removes the previous versions of a purged note
+func forgetNoteHistory(userID int64, noteID int64) error {
+	noteHistoryMutex.Lock()
+	defer noteHistoryMutex.Unlock()
+
+	data, err := loadNoteHistory(userID)
+	if err != nil {
+		return err
+	}
+	if _, ok := data.Notes[noteID]; !ok {
+		return nil
+	}
+	delete(data.Notes, noteID)
+	return saveDocument(userID, noteHistoryDocument, data)
+}
+
+func loadNoteHistory(userID int64) (models.NoteHistoryData, error) {
+	var data models.NoteHistoryData
+	if err := loadDocument(userID, noteHistoryDocument, &data); err != nil {
+		return models.NoteHistoryData{}, err
+	}
+	if data.Notes == nil {
+		data.Notes = make(map[int64][]models.NoteRevision)
+	}
+	return data, nil
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"studybuddy/models"
+	"sync"
+)
+
+// notificationsDocument is the name of the per-user document holding
+// notification settings, push subscriptions and delivery state
+const notificationsDocument = "notifications"
+
+// defaultLeadMinutes is how long before an item users are notified by default
+const defaultLeadMinutes = 15
+
+var notificationsMutex sync.Mutex
+
+// LoadNotifications loads a user's notification settings and state
+func LoadNotifications(userID int64) (models.NotificationsData, error) {
+	notificationsMutex.Lock()
+	defer notificationsMutex.Unlock()
+
+	data := models.NotificationsData{Settings: models.NotificationSettings{LeadMinutes: defaultLeadMinutes}}
+	err := loadDocument(userID, notificationsDocument, &data)
+	return data, err
+}
+
+// UpdateNotifications loads a user's notification settings and state,
+// applies fn and saves the result, holding the notifications lock for the
+// whole sequence. Nothing is saved when fn returns an error.
+func UpdateNotifications(userID int64, fn func(data *models.NotificationsData) error) (models.NotificationsData, error) {
+	notificationsMutex.Lock()
+	defer notificationsMutex.Unlock()
+
+	data := models.NotificationsData{Settings: models.NotificationSettings{LeadMinutes: defaultLeadMinutes}}
+	if err := loadDocument(userID, notificationsDocument, &data); err != nil {
+		return models.NotificationsData{}, err
+	}
+
+	if err := fn(&data); err != nil {
+		return models.NotificationsData{}, err
+	}
+
+	if err := saveDocument(userID, notificationsDocument, data); err != nil {
+		return models.NotificationsData{}, err
+	}
+	return data, nil
+}
+
+// RemovePushSubscription deletes a push subscription by endpoint
+func RemovePushSubscription(userID int64, endpoint string) error {
+	_, err := UpdateNotifications(userID, func(data *models.NotificationsData) error {
+		subscriptions := make([]models.PushSubscription, 0, len(data.Subscriptions))
+		for _, subscription := range data.Subscriptions {
+			if subscription.Endpoint != endpoint {
+				subscriptions = append(subscriptions, subscription)
+			}
+		}
+		data.Subscriptions = subscriptions
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"studybuddy/models"
+	"sync"
+	"time"
+)
+
+// PasswordResetTTL is how long a password reset token stays valid
+const PasswordResetTTL = time.Hour
+
+// ErrInvalidResetToken is returned for unknown, used or expired reset tokens
+var ErrInvalidResetToken = errors.New("token de redefinição inválido ou expirado")
+
+// passwordReset is a pending reset request. Only the SHA-256 hash of the
+// token is stored, so a leaked file cannot be used to reset passwords. It
+// references the user by ID, so the token does not follow their email.
+type passwordReset struct {
+	UserID    int64     `json:"userId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+var resetMutex sync.Mutex
+
+// resetTokensDocument is the name of the document holding pending resets
+const resetTokensDocument = "reset_tokens"
+
+// hashToken returns the hex encoded SHA-256 hash of a token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateToken returns a random hex encoded token of n bytes
+func generateToken(n int) (string, error) {
+	bytes := make([]byte, n)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// loadResetTokens reads the pending reset tokens, dropping expired ones and
+// the ones issued before resets were keyed by user ID.
+// A read error is returned rather than treated as an empty document, which
+// would then be written back over every pending reset.
+// Must be called with resetMutex held.
+func loadResetTokens() (map[string]passwordReset, error) {
+	resets := make(map[string]passwordReset)
+	if err := loadDocument(systemScope, resetTokensDocument, &resets); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for hash, reset := range resets {
+		if now.After(reset.ExpiresAt) || reset.UserID == 0 {
+			delete(resets, hash)
+		}
+	}
+	return resets, nil
+}
+
+// saveResetTokens writes the pending reset tokens.
+// Must be called with resetMutex held.
+func saveResetTokens(resets map[string]passwordReset) error {
+	return saveDocument(systemScope, resetTokensDocument, resets)
+}
+
+// CreatePasswordResetToken issues a single-use reset token for a user,
+// invalidating any token previously issued to them
+func CreatePasswordResetToken(userID int64) (string, error) {
+	resetMutex.Lock()
+	defer resetMutex.Unlock()
+
+	token, err := generateToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	resets, err := loadResetTokens()
+	if err != nil {
+		return "", err
+	}
+	removeUserResets(resets, userID)
+	resets[hashToken(token)] = passwordReset{
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(PasswordResetTTL),
+	}
+
+	if err := saveResetTokens(resets); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ResetPassword validates a reset token, sets the new password and
+// consumes the token
+func ResetPassword(token, newPassword string) (models.User, error) {
+	resetMutex.Lock()
+	defer resetMutex.Unlock()
+
+	resets, err := loadResetTokens()
+	if err != nil {
+		return models.User{}, err
+	}
+	hash := hashToken(token)
+	reset, exists := resets[hash]
+	if !exists {
+		return models.User{}, ErrInvalidResetToken
+	}
+
+	user, err := UpdatePassword(reset.UserID, newPassword)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	delete(resets, hash)
+	if err := saveResetTokens(resets); err != nil {
+		return models.User{}, err
+	}
+
+	// Whoever knew the old password must not stay logged in
+	if err := RevokeAllSessions(user.ID, ""); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// DeletePasswordResets invalidates the pending reset tokens of a user, e.g.
+// after their email changed and the tokens were sent to the old address
+func DeletePasswordResets(userID int64) error {
+	resetMutex.Lock()
+	defer resetMutex.Unlock()
+
+	resets, err := loadResetTokens()
+	if err != nil {
+		return err
+	}
+	if removeUserResets(resets, userID) {
+		return saveResetTokens(resets)
+	}
+	return nil
+}
+
+// removeUserResets deletes the resets of a user from resets and reports
+// whether there was any
+func removeUserResets(resets map[string]passwordReset, userID int64) bool {
+	removed := false
+	for hash, reset := range resets {
+		if reset.UserID == userID {
+			delete(resets, hash)
+			removed = true
+		}
+	}
+	return removed
+}
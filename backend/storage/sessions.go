@@ -0,0 +1,305 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"studybuddy/models"
+	"sync"
+	"time"
+)
+
+// Token lifetimes
+const (
+	AccessTokenTTL          = 15 * time.Minute
+	refreshTokenTTL         = 24 * time.Hour
+	refreshTokenRememberTTL = 7 * 24 * time.Hour
+)
+
+// ErrInvalidRefreshToken is returned for unknown, rotated, revoked or expired refresh tokens
+var ErrInvalidRefreshToken = errors.New("sessão inválida ou expirada")
+
+// sessionsDocument is the name of the per-user document holding sessions
+const sessionsDocument = "sessions"
+
+// session is a logged in device. Only hashes of refresh tokens are stored.
+type session struct {
+	ID           string    `json:"id"`
+	TokenHash    string    `json:"tokenHash"`
+	PreviousHash string    `json:"previousHash,omitempty"` // Hash of the last rotated token, used to detect reuse
+	RememberMe   bool      `json:"rememberMe"`
+	UserAgent    string    `json:"userAgent"`
+	CreatedAt    time.Time `json:"createdAt"`
+	LastUsedAt   time.Time `json:"lastUsedAt"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+var (
+	// sessionsCache keeps each user's sessions in memory so the auth
+	// middleware does not hit the backend on every request
+	sessionsCache = make(map[int64][]session)
+	sessionsMutex sync.Mutex
+)
+
+// loadSessions returns a user's unexpired sessions.
+// Must be called with sessionsMutex held.
+func loadSessions(userID int64) ([]session, error) {
+	sessions, cached := sessionsCache[userID]
+	if !cached {
+		bytes, err := backend.ReadDocument(userID, sessionsDocument)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(bytes, &sessions); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	now := time.Now()
+	active := make([]session, 0, len(sessions))
+	for _, s := range sessions {
+		if now.Before(s.ExpiresAt) {
+			active = append(active, s)
+		}
+	}
+	sessionsCache[userID] = active
+	return active, nil
+}
+
+// saveSessions persists a user's sessions.
+// Must be called with sessionsMutex held.
+func saveSessions(userID int64, sessions []session) error {
+	bytes, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := backend.WriteDocument(userID, sessionsDocument, bytes); err != nil {
+		delete(sessionsCache, userID)
+		return err
+	}
+	sessionsCache[userID] = sessions
+	return nil
+}
+
+// refreshTokenFor builds a refresh token. The user ID prefix tells which
+// user's sessions to search; the secret part is random.
+func refreshTokenFor(userID int64) (string, error) {
+	secret, err := generateToken(32)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d.%s", userID, secret), nil
+}
+
+// parseRefreshToken extracts the user ID from a refresh token
+func parseRefreshToken(token string) (int64, bool) {
+	prefix, _, found := strings.Cut(token, ".")
+	if !found {
+		return 0, false
+	}
+	userID, err := strconv.ParseInt(prefix, 10, 64)
+	return userID, err == nil
+}
+
+// CreateSession starts a new session and returns its ID and refresh token
+func CreateSession(userID int64, rememberMe bool, userAgent string) (string, string, error) {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	sessions, err := loadSessions(userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	sessionID, err := generateToken(8)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err := refreshTokenFor(userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	ttl := refreshTokenTTL
+	if rememberMe {
+		ttl = refreshTokenRememberTTL
+	}
+	now := time.Now()
+	sessions = append(sessions, session{
+		ID:         sessionID,
+		TokenHash:  hashToken(refreshToken),
+		RememberMe: rememberMe,
+		UserAgent:  userAgent,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(ttl),
+	})
+
+	if err := saveSessions(userID, sessions); err != nil {
+		return "", "", err
+	}
+	return sessionID, refreshToken, nil
+}
+
+// RefreshSession validates a refresh token and rotates it, returning the
+// user ID, session ID and the new refresh token. Presenting an already
+// rotated token revokes the whole session, since it may have been stolen.
+func RefreshSession(refreshToken string) (int64, string, string, error) {
+	userID, ok := parseRefreshToken(refreshToken)
+	if !ok {
+		return 0, "", "", ErrInvalidRefreshToken
+	}
+
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	sessions, err := loadSessions(userID)
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	hash := hashToken(refreshToken)
+	for i, s := range sessions {
+		if s.PreviousHash == hash {
+			sessions = append(sessions[:i], sessions[i+1:]...)
+			if err := saveSessions(userID, sessions); err != nil {
+				return 0, "", "", err
+			}
+			return 0, "", "", ErrInvalidRefreshToken
+		}
+		if s.TokenHash != hash {
+			continue
+		}
+
+		newToken, err := refreshTokenFor(userID)
+		if err != nil {
+			return 0, "", "", err
+		}
+
+		ttl := refreshTokenTTL
+		if s.RememberMe {
+			ttl = refreshTokenRememberTTL
+		}
+		now := time.Now()
+		sessions[i].PreviousHash = s.TokenHash
+		sessions[i].TokenHash = hashToken(newToken)
+		sessions[i].LastUsedAt = now
+		sessions[i].ExpiresAt = now.Add(ttl)
+
+		if err := saveSessions(userID, sessions); err != nil {
+			return 0, "", "", err
+		}
+		return userID, s.ID, newToken, nil
+	}
+	return 0, "", "", ErrInvalidRefreshToken
+}
+
+// IsSessionActive reports whether a session exists and has not expired
+func IsSessionActive(userID int64, sessionID string) bool {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	sessions, err := loadSessions(userID)
+	if err != nil {
+		return false
+	}
+	for _, s := range sessions {
+		if s.ID == sessionID {
+			return true
+		}
+	}
+	return false
+}
+
+// ListSessions returns a user's active sessions, marking currentID as current
+func ListSessions(userID int64, currentID string) ([]models.SessionInfo, error) {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	sessions, err := loadSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]models.SessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		infos = append(infos, models.SessionInfo{
+			ID:         s.ID,
+			UserAgent:  s.UserAgent,
+			CreatedAt:  s.CreatedAt.Format(time.RFC3339),
+			LastUsedAt: s.LastUsedAt.Format(time.RFC3339),
+			ExpiresAt:  s.ExpiresAt.Format(time.RFC3339),
+			Current:    s.ID == currentID,
+		})
+	}
+	return infos, nil
+}
+
+// RevokeSession ends one session of a user
+func RevokeSession(userID int64, sessionID string) error {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	sessions, err := loadSessions(userID)
+	if err != nil {
+		return err
+	}
+	for i, s := range sessions {
+		if s.ID == sessionID {
+			return saveSessions(userID, append(sessions[:i], sessions[i+1:]...))
+		}
+	}
+	return ErrNotFound
+}
+
+// RevokeSessionByRefreshToken ends the session a refresh token belongs to
+func RevokeSessionByRefreshToken(refreshToken string) error {
+	userID, ok := parseRefreshToken(refreshToken)
+	if !ok {
+		return ErrInvalidRefreshToken
+	}
+
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	sessions, err := loadSessions(userID)
+	if err != nil {
+		return err
+	}
+	hash := hashToken(refreshToken)
+	for i, s := range sessions {
+		if s.TokenHash == hash {
+			return saveSessions(userID, append(sessions[:i], sessions[i+1:]...))
+		}
+	}
+	return ErrInvalidRefreshToken
+}
+
+// RevokeAllSessions ends every session of a user except exceptID (which may be empty)
+func RevokeAllSessions(userID int64, exceptID string) error {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	sessions, err := loadSessions(userID)
+	if err != nil {
+		return err
+	}
+	kept := make([]session, 0, 1)
+	for _, s := range sessions {
+		if s.ID == exceptID {
+			kept = append(kept, s)
+		}
+	}
+	return saveSessions(userID, kept)
+}
+
+// forgetSessions drops a user's cached sessions after their data was deleted
+func forgetSessions(userID int64) {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+	delete(sessionsCache, userID)
+}
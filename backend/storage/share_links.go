@@ -0,0 +1,312 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"studybuddy/models"
+	"sync"
+	"time"
+)
+
+// Share link errors
+var (
+	ErrShareLinkNotFound  = errors.New("link não encontrado, expirado ou revogado")
+	ErrShareLinkExhausted = errors.New("este link atingiu o limite de downloads")
+	ErrShareLinkPassword  = errors.New("senha incorreta")
+)
+
+// shareLink is a public link to a node of a user. Only the SHA-256 hash of
+// the token is stored, and the password is hashed with bcrypt.
+type shareLink struct {
+	ID           string    `json:"id"`
+	OwnerID      int64     `json:"ownerId"`
+	NodeID       string    `json:"nodeId"`
+	CreatedAt    time.Time `json:"createdAt"`
+	ExpiresAt    time.Time `json:"expiresAt,omitempty"`
+	MaxDownloads int       `json:"maxDownloads,omitempty"`
+	Downloads    int       `json:"downloads"`
+	PasswordHash string    `json:"passwordHash,omitempty"`
+}
+
+// ShareLinkAccess identifies the node a public link opens
+type ShareLinkAccess struct {
+	LinkID   string
+	OwnerID  int64
+	NodeID   string
+	Password bool // The link is protected by a password
+	// ExpiresAt is when the link expires, or the zero time
+	ExpiresAt time.Time
+	// Exhausted is set when the link reached its download limit
+	Exhausted bool
+}
+
+var shareLinksMutex sync.Mutex
+
+// shareLinksDocument is the name of the document holding public links
+const shareLinksDocument = "share_links"
+
+// loadShareLinks reads the public links by token hash, dropping expired
+// ones. The links of every user share one document, so a read error is
+// returned rather than treated as an empty document that would then be
+// written back. Must be called with shareLinksMutex held.
+func loadShareLinks() (map[string]shareLink, error) {
+	links := make(map[string]shareLink)
+	if err := loadDocument(systemScope, shareLinksDocument, &links); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for hash, link := range links {
+		if !link.ExpiresAt.IsZero() && now.After(link.ExpiresAt) {
+			delete(links, hash)
+		}
+	}
+	return links, nil
+}
+
+// saveShareLinks writes the public links.
+// Must be called with shareLinksMutex held.
+func saveShareLinks(links map[string]shareLink) error {
+	return saveDocument(systemScope, shareLinksDocument, links)
+}
+
+// CreateShareLink issues a public link to a node of the owner and returns
+// its token. A zero expiresAt never expires, a zero maxDownloads allows any
+// number of downloads and an empty password leaves the link open.
+func CreateShareLink(ownerID int64, nodeID string, expiresAt time.Time, maxDownloads int, password string) (string, models.ShareLink, error) {
+	link := shareLink{
+		ID:           generateID("link"),
+		OwnerID:      ownerID,
+		NodeID:       nodeID,
+		CreatedAt:    time.Now(),
+		ExpiresAt:    expiresAt,
+		MaxDownloads: maxDownloads,
+	}
+	if password != "" {
+		hash, err := HashPassword(password)
+		if err != nil {
+			return "", models.ShareLink{}, err
+		}
+		link.PasswordHash = hash
+	}
+
+	token, err := generateToken(24)
+	if err != nil {
+		return "", models.ShareLink{}, err
+	}
+
+	shareLinksMutex.Lock()
+	defer shareLinksMutex.Unlock()
+
+	links, err := loadShareLinks()
+	if err != nil {
+		return "", models.ShareLink{}, err
+	}
+	links[hashToken(token)] = link
+	if err := saveShareLinks(links); err != nil {
+		return "", models.ShareLink{}, err
+	}
+	return token, link.info(), nil
+}
+
+// ListShareLinks returns the owner's public links that did not expire,
+// oldest first
+func ListShareLinks(ownerID int64) ([]models.ShareLink, error) {
+	shareLinksMutex.Lock()
+	defer shareLinksMutex.Unlock()
+
+	links, err := loadShareLinks()
+	if err != nil {
+		return nil, err
+	}
+	var owned []shareLink
+	for _, link := range links {
+		if link.OwnerID == ownerID {
+			owned = append(owned, link)
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool { return owned[i].CreatedAt.Before(owned[j].CreatedAt) })
+
+	infos := make([]models.ShareLink, 0, len(owned))
+	for _, link := range owned {
+		infos = append(infos, link.info())
+	}
+	return infos, nil
+}
+
+// RevokeShareLink deletes one of the owner's public links
+func RevokeShareLink(ownerID int64, linkID string) error {
+	shareLinksMutex.Lock()
+	defer shareLinksMutex.Unlock()
+
+	links, err := loadShareLinks()
+	if err != nil {
+		return err
+	}
+	for hash, link := range links {
+		if link.ID == linkID && link.OwnerID == ownerID {
+			delete(links, hash)
+			return saveShareLinks(links)
+		}
+	}
+	return ErrShareLinkNotFound
+}
+
+// removeShareLinks deletes every public link of a user
+func removeShareLinks(ownerID int64) error {
+	shareLinksMutex.Lock()
+	defer shareLinksMutex.Unlock()
+
+	links, err := loadShareLinks()
+	if err != nil {
+		return err
+	}
+	removed := false
+	for hash, link := range links {
+		if link.OwnerID == ownerID {
+			delete(links, hash)
+			removed = true
+		}
+	}
+	if !removed {
+		return nil
+	}
+	return saveShareLinks(links)
+}
+
+// FindShareLink returns what a public link token opens. It does not check
+// the password.
+func FindShareLink(token string) (ShareLinkAccess, error) {
+	shareLinksMutex.Lock()
+	defer shareLinksMutex.Unlock()
+
+	links, err := loadShareLinks()
+	if err != nil {
+		return ShareLinkAccess{}, err
+	}
+	link, exists := links[hashToken(token)]
+	if !exists {
+		return ShareLinkAccess{}, ErrShareLinkNotFound
+	}
+	if _, exists := GetUserByID(link.OwnerID); !exists {
+		return ShareLinkAccess{}, ErrShareLinkNotFound
+	}
+	return link.access(), nil
+}
+
+// CheckShareLinkPassword verifies the password of a public link. Links
+// without a password accept any password.
+func CheckShareLinkPassword(token, password string) error {
+	shareLinksMutex.Lock()
+	links, err := loadShareLinks()
+	shareLinksMutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	link, exists := links[hashToken(token)]
+	if !exists {
+		return ErrShareLinkNotFound
+	}
+	// bcrypt is slow, so the hash is compared without holding the lock
+	if link.PasswordHash != "" && !CheckPasswordHash(password, link.PasswordHash) {
+		return ErrShareLinkPassword
+	}
+	return nil
+}
+
+// RecordShareLinkDownload counts a file downloaded through a public link,
+// failing once the link reached its download limit
+func RecordShareLinkDownload(linkID string) error {
+	shareLinksMutex.Lock()
+	defer shareLinksMutex.Unlock()
+
+	links, err := loadShareLinks()
+	if err != nil {
+		return err
+	}
+	for hash, link := range links {
+		if link.ID != linkID {
+			continue
+		}
+		if link.exhausted() {
+			return ErrShareLinkExhausted
+		}
+		link.Downloads++
+		links[hash] = link
+		return saveShareLinks(links)
+	}
+	return ErrShareLinkNotFound
+}
+
+// ShareLinkByID returns what a public link opens by the ID of the link, for
+// file URLs issued through it
+func ShareLinkByID(linkID string) (ShareLinkAccess, error) {
+	shareLinksMutex.Lock()
+	defer shareLinksMutex.Unlock()
+
+	links, err := loadShareLinks()
+	if err != nil {
+		return ShareLinkAccess{}, err
+	}
+	for _, link := range links {
+		if link.ID == linkID {
+			return link.access(), nil
+		}
+	}
+	return ShareLinkAccess{}, ErrShareLinkNotFound
+}
+
+// IsWithinNode reports whether nodeID is rootID or one of its descendants
+// in the owner's tree
+func IsWithinNode(ownerID int64, rootID, nodeID string) (bool, error) {
+	materialsMutex.Lock()
+	defer materialsMutex.Unlock()
+
+	index, err := userMaterialIndex(ownerID)
+	if err != nil {
+		return false, err
+	}
+	node, ok := index[nodeID]
+	// The number of steps is bounded in case of a corrupted parent chain
+	for steps := 0; ok && steps <= len(index); steps++ {
+		if node.ID == rootID {
+			return true, nil
+		}
+		node, ok = index[node.ParentID]
+	}
+	return false, nil
+}
+
+// info returns the description of a link shown to its owner
+func (link shareLink) info() models.ShareLink {
+	info := models.ShareLink{
+		ID:           link.ID,
+		NodeID:       link.NodeID,
+		CreatedAt:    link.CreatedAt.Format(time.RFC3339),
+		MaxDownloads: link.MaxDownloads,
+		Downloads:    link.Downloads,
+		HasPassword:  link.PasswordHash != "",
+	}
+	if !link.ExpiresAt.IsZero() {
+		info.ExpiresAt = link.ExpiresAt.Format(time.RFC3339)
+	}
+	return info
+}
+
+// exhausted reports whether the link reached its download limit
+func (link shareLink) exhausted() bool {
+	return link.MaxDownloads > 0 && link.Downloads >= link.MaxDownloads
+}
+
+// access returns what a link opens
+func (link shareLink) access() ShareLinkAccess {
+	return ShareLinkAccess{
+		LinkID:    link.ID,
+		OwnerID:   link.OwnerID,
+		NodeID:    link.NodeID,
+		Password:  link.PasswordHash != "",
+		ExpiresAt: link.ExpiresAt,
+		Exhausted: link.exhausted(),
+	}
+}
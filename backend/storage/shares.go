@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"errors"
+	"studybuddy/models"
+	"sync"
+	"time"
+)
+
+// sharedDocument is the name of the per-user document listing the nodes
+// other users shared with the user
+const sharedDocument = "shared_with_me"
+
+var sharedMutex sync.Mutex
+
+// roleRanks orders the roles from the weakest to the strongest
+var roleRanks = map[string]int{
+	models.RoleViewer: 1,
+	models.RoleEditor: 2,
+	models.RoleOwner:  3,
+}
+
+// MaterialAccess describes how a user may act on a material or folder
+type MaterialAccess struct {
+	OwnerID int64  // User whose tree holds the node
+	Role    string // One of the models.Role constants
+	// Direct is set when the node itself is shared with the user, rather
+	// than through one of its parent folders
+	Direct bool
+}
+
+// ValidShareRole reports whether role can be granted to another user
+func ValidShareRole(role string) bool {
+	return role == models.RoleViewer || role == models.RoleEditor
+}
+
+// RoleAllows reports whether role includes the rights of required
+func RoleAllows(role, required string) bool {
+	return roleRanks[role] >= roleRanks[required]
+}
+
+// ResolveMaterialAccess returns who owns a node the user refers to and the
+// user's role on it. Nodes of the user's own tree, and nodes that are not
+// shared with the user, are resolved to the user's own tree, so unknown
+// nodes fail as before and other users' nodes stay hidden.
+func ResolveMaterialAccess(userID int64, nodeID string) (MaterialAccess, error) {
+	own := MaterialAccess{OwnerID: userID, Role: models.RoleOwner}
+	if _, ok, err := FindMaterial(userID, nodeID); err != nil || ok {
+		return own, err
+	}
+
+	data, err := LoadSharedWithMe(userID)
+	if err != nil {
+		return own, err
+	}
+	checked := make(map[int64]bool)
+	for _, ref := range data.Items {
+		if checked[ref.OwnerID] {
+			continue
+		}
+		checked[ref.OwnerID] = true
+		if _, exists := GetUserByID(ref.OwnerID); !exists {
+			continue
+		}
+		access, ok, err := sharedAccess(ref.OwnerID, userID, nodeID)
+		if err != nil {
+			return own, err
+		}
+		if ok {
+			return access, nil
+		}
+	}
+	return own, nil
+}
+
+// sharedAccess returns the role of userID on a node of the owner's tree,
+// granted by the node or by one of its parent folders
+func sharedAccess(ownerID, userID int64, nodeID string) (MaterialAccess, bool, error) {
+	materialsMutex.Lock()
+	defer materialsMutex.Unlock()
+
+	index, err := userMaterialIndex(ownerID)
+	if err != nil {
+		return MaterialAccess{}, false, err
+	}
+
+	access := MaterialAccess{OwnerID: ownerID}
+	node, ok := index[nodeID]
+	// The number of steps is bounded in case of a corrupted parent chain
+	for steps := 0; ok && steps <= len(index); steps++ {
+		if share := findShare(node.Shares, userID); share != nil {
+			if roleRanks[share.Role] > roleRanks[access.Role] {
+				access.Role = share.Role
+			}
+			if node.ID == nodeID {
+				access.Direct = true
+			}
+		}
+		if node.ParentID == "" {
+			break
+		}
+		node, ok = index[node.ParentID]
+	}
+	return access, access.Role != "", nil
+}
+
+// SetShare grants userID a role on node, replacing a previous grant, and
+// returns the share
+func SetShare(node *models.MaterialNode, userID int64, role string) (models.Share, error) {
+	if node.ID == "root" {
+		return models.Share{}, errors.New("não é possível compartilhar a pasta raiz")
+	}
+	if !ValidShareRole(role) {
+		return models.Share{}, errors.New("permissão inválida, use viewer ou editor")
+	}
+	if share := findShare(node.Shares, userID); share != nil {
+		share.Role = role
+		return *share, nil
+	}
+	share := models.Share{
+		UserID:   userID,
+		Role:     role,
+		SharedAt: time.Now().Format(time.RFC3339),
+	}
+	node.Shares = append(node.Shares, share)
+	return share, nil
+}
+
+// RemoveShare revokes the access of userID to node. It reports whether the
+// node was shared with the user.
+func RemoveShare(node *models.MaterialNode, userID int64) bool {
+	for i, share := range node.Shares {
+		if share.UserID == userID {
+			node.Shares = append(node.Shares[:i], node.Shares[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// findShare returns the share of userID in shares, or nil
+func findShare(shares []models.Share, userID int64) *models.Share {
+	for i := range shares {
+		if shares[i].UserID == userID {
+			return &shares[i]
+		}
+	}
+	return nil
+}
+
+// LoadSharedWithMe loads the nodes other users shared with a user
+func LoadSharedWithMe(userID int64) (models.SharedWithMeData, error) {
+	sharedMutex.Lock()
+	defer sharedMutex.Unlock()
+
+	return loadSharedWithMe(userID)
+}
+
+// AddSharedRef records that a node was shared with userID. It is meant to
+// run inside the owner's UpdateMaterials, so the grant and the reference
+// are saved together.
+func AddSharedRef(userID int64, ref models.SharedRef) error {
+	sharedMutex.Lock()
+	defer sharedMutex.Unlock()
+
+	data, err := loadSharedWithMe(userID)
+	if err != nil {
+		return err
+	}
+	for _, item := range data.Items {
+		if item == ref {
+			return nil
+		}
+	}
+	data.Items = append(data.Items, ref)
+	return saveDocument(userID, sharedDocument, data)
+}
+
+// RemoveSharedRef forgets a node that is no longer shared with userID
+func RemoveSharedRef(userID int64, ref models.SharedRef) error {
+	sharedMutex.Lock()
+	defer sharedMutex.Unlock()
+
+	data, err := loadSharedWithMe(userID)
+	if err != nil {
+		return err
+	}
+	kept := make([]models.SharedRef, 0, len(data.Items))
+	for _, item := range data.Items {
+		if item != ref {
+			kept = append(kept, item)
+		}
+	}
+	if len(kept) == len(data.Items) {
+		return nil
+	}
+	data.Items = kept
+	return saveDocument(userID, sharedDocument, data)
+}
+
+// ListSharedWithMe returns the nodes other users currently share with the
+// user, with their descendants. Nodes that were deleted, or whose share was
+// revoked, are left out; a deleted node shows up again if it is restored
+// from the owner's trash.
+func ListSharedWithMe(userID int64) ([]models.SharedNode, error) {
+	data, err := LoadSharedWithMe(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []models.SharedNode{}
+	for _, ref := range data.Items {
+		owner, exists := GetUserByID(ref.OwnerID)
+		if !exists {
+			continue
+		}
+		tree, err := LoadMaterials(ref.OwnerID)
+		if err != nil {
+			return nil, err
+		}
+		node := FindNodeByID(tree.Root, ref.NodeID)
+		if node == nil || findShare(node.Shares, userID) == nil {
+			continue
+		}
+		access, _, err := sharedAccess(ref.OwnerID, userID, ref.NodeID)
+		if err != nil {
+			return nil, err
+		}
+		StripShares(node)
+		nodes = append(nodes, models.SharedNode{
+			MaterialNode: node,
+			OwnerID:      owner.ID,
+			OwnerName:    owner.Name,
+			OwnerEmail:   owner.Email,
+			Role:         access.Role,
+			Revision:     tree.Revision,
+		})
+	}
+	return nodes, nil
+}
+
+// StripShares removes the share lists of node and its descendants, so users
+// a folder is shared with do not see who else can access it
+func StripShares(node *models.MaterialNode) {
+	node.Shares = nil
+	for _, child := range node.Children {
+		StripShares(child)
+	}
+}
+
+func loadSharedWithMe(userID int64) (models.SharedWithMeData, error) {
+	var data models.SharedWithMeData
+	if err := loadDocument(userID, sharedDocument, &data); err != nil {
+		return models.SharedWithMeData{}, err
+	}
+	if data.Items == nil {
+		data.Items = []models.SharedRef{}
+	}
+	return data, nil
+}
@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"studybuddy/models"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the tables used by SQLiteBackend.
+// Application data and materials trees are stored as JSON documents, one
+// row per user, mirroring the JSON backend.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	id         INTEGER PRIMARY KEY,
+	email      TEXT NOT NULL UNIQUE,
+	password   TEXT NOT NULL,
+	name       TEXT NOT NULL DEFAULT '',
+	created_at TEXT NOT NULL DEFAULT ''
+);
+CREATE TABLE IF NOT EXISTS app_data (
+	user_id    INTEGER PRIMARY KEY,
+	body       TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS materials (
+	user_id    INTEGER PRIMARY KEY,
+	body       TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS documents (
+	user_id    INTEGER NOT NULL,
+	name       TEXT NOT NULL,
+	body       BLOB NOT NULL,
+	updated_at TEXT NOT NULL,
+	PRIMARY KEY (user_id, name)
+);
+`
+
+// SQLiteBackend stores everything in an embedded SQLite database
+type SQLiteBackend struct {
+	db *sql.DB
+}
+
+// NewSQLiteBackend opens (creating if needed) the SQLite database at path
+func NewSQLiteBackend(path string) (*SQLiteBackend, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; one connection avoids "database is locked" errors
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteBackend{db: db}, nil
+}
+
+// now returns the timestamp stored in updated_at columns
+func (b *SQLiteBackend) now() string {
+	return time.Now().Format(time.RFC3339)
+}
+
+// LoadUsers loads all users from the users table
+func (b *SQLiteBackend) LoadUsers() (map[string]models.User, error) {
+	rows, err := b.db.Query(`SELECT id, email, password, name, created_at FROM users`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	loaded := make(map[string]models.User)
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Email, &user.Password, &user.Name, &user.CreatedAt); err != nil {
+			return nil, err
+		}
+		loaded[user.Email] = user
+	}
+	return loaded, rows.Err()
+}
+
+// SaveUser inserts or updates a user row
+func (b *SQLiteBackend) SaveUser(user models.User) error {
+	_, err := b.db.Exec(`
+		INSERT INTO users (id, email, password, name, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			email = excluded.email,
+			password = excluded.password,
+			name = excluded.name,
+			created_at = excluded.created_at`,
+		user.ID, user.Email, user.Password, user.Name, user.CreatedAt)
+	return err
+}
+
+// DeleteUser removes a user row
+func (b *SQLiteBackend) DeleteUser(id int64) error {
+	_, err := b.db.Exec(`DELETE FROM users WHERE id = ?`, id)
+	return err
+}
+
+// loadJSON reads a JSON body from a single-row query into v
+func (b *SQLiteBackend) loadJSON(query string, userID int64, v interface{}) error {
+	var body []byte
+	err := b.db.QueryRow(query, userID).Scan(&body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// LoadData loads a user's application data
+func (b *SQLiteBackend) LoadData(userID int64) (models.AppData, error) {
+	var data models.AppData
+	if err := b.loadJSON(`SELECT body FROM app_data WHERE user_id = ?`, userID, &data); err != nil {
+		return models.AppData{}, err
+	}
+	return data, nil
+}
+
+// SaveData replaces a user's application data
+func (b *SQLiteBackend) SaveData(userID int64, data models.AppData) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = b.db.Exec(`
+		INSERT INTO app_data (user_id, body, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET body = excluded.body, updated_at = excluded.updated_at`,
+		userID, string(body), b.now())
+	return err
+}
+
+// LoadMaterials loads a user's materials tree
+func (b *SQLiteBackend) LoadMaterials(userID int64) (*models.MaterialsTree, error) {
+	var tree models.MaterialsTree
+	if err := b.loadJSON(`SELECT body FROM materials WHERE user_id = ?`, userID, &tree); err != nil {
+		return nil, err
+	}
+	return &tree, nil
+}
+
+// SaveMaterials replaces a user's materials tree
+func (b *SQLiteBackend) SaveMaterials(userID int64, tree *models.MaterialsTree) error {
+	body, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	_, err = b.db.Exec(`
+		INSERT INTO materials (user_id, body, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET body = excluded.body, updated_at = excluded.updated_at`,
+		userID, string(body), b.now())
+	return err
+}
+
+// ReadDocument reads a named document
+func (b *SQLiteBackend) ReadDocument(userID int64, name string) ([]byte, error) {
+	var body []byte
+	err := b.db.QueryRow(`SELECT body FROM documents WHERE user_id = ? AND name = ?`, userID, name).Scan(&body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return body, err
+}
+
+// WriteDocument inserts or replaces a named document
+func (b *SQLiteBackend) WriteDocument(userID int64, name string, body []byte) error {
+	_, err := b.db.Exec(`
+		INSERT INTO documents (user_id, name, body, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id, name) DO UPDATE SET body = excluded.body, updated_at = excluded.updated_at`,
+		userID, name, body, b.now())
+	return err
+}
+
+// DeleteDocument removes a named document
+func (b *SQLiteBackend) DeleteDocument(userID int64, name string) error {
+	_, err := b.db.Exec(`DELETE FROM documents WHERE user_id = ? AND name = ?`, userID, name)
+	return err
+}
+
+// ListDocuments lists the names of a user's documents
+func (b *SQLiteBackend) ListDocuments(userID int64) ([]string, error) {
+	rows, err := b.db.Query(`SELECT name FROM documents WHERE user_id = ? ORDER BY name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// DeleteUserData removes a user's data, materials and documents in one transaction
+func (b *SQLiteBackend) DeleteUserData(userID int64) error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM app_data WHERE user_id = ?`,
+		`DELETE FROM materials WHERE user_id = ?`,
+		`DELETE FROM documents WHERE user_id = ?`,
+	} {
+		if _, err := tx.Exec(query, userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Close closes the database
+func (b *SQLiteBackend) Close() error {
+	return b.db.Close()
+}
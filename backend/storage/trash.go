@@ -0,0 +1,374 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"studybuddy/models"
+	"sync"
+	"time"
+)
+
+// trashDocument is the name of the per-user document holding deleted items
+const trashDocument = "trash"
+
+// defaultTrashRetention is how long deleted items are kept by default
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+// Trash errors
+var (
+	ErrTrashItemNotFound = errors.New("item não encontrado na lixeira")
+	ErrRestoreConflict   = errors.New("já existe um item com o mesmo identificador")
+)
+
+var trashMutex sync.Mutex
+
+// restoreMutex serializes restores and purges, so a purge cannot delete the
+// files, texts and positions of an item while it is being restored. It is
+// taken before any other lock.
+var restoreMutex sync.Mutex
+
+// TrashRetention returns how long deleted items stay in the trash, set by
+// TRASH_RETENTION_DAYS (default 30 days)
+func TrashRetention() time.Duration {
+	if value := os.Getenv("TRASH_RETENTION_DAYS"); value != "" {
+		if days, err := strconv.Atoi(value); err == nil && days > 0 {
+			return time.Duration(days) * 24 * time.Hour
+		}
+		log.Printf("WARNING: Invalid TRASH_RETENTION_DAYS %q, using default", value)
+	}
+	return defaultTrashRetention
+}
+
+// LoadTrash loads a user's deleted items, oldest first
+func LoadTrash(userID int64) (models.TrashData, error) {
+	trashMutex.Lock()
+	defer trashMutex.Unlock()
+
+	return loadTrash(userID)
+}
+
+// MoveToTrash records a deleted item. TrashData and TrashMaterials call it
+// once the item was removed from its original place.
+func MoveToTrash(userID int64, item models.TrashItem) error {
+	trashMutex.Lock()
+	defer trashMutex.Unlock()
+
+	data, err := loadTrash(userID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	item.ID = generateID("trash")
+	item.DeletedAt = now.Format(time.RFC3339)
+	item.ExpiresAt = now.Add(TrashRetention()).Format(time.RFC3339)
+	data.Items = append(data.Items, item)
+	return saveDocument(userID, trashDocument, data)
+}
+
+// FolderPath returns the folders from the root to the parent of a node,
+// excluding the root
+func FolderPath(root *models.MaterialNode, nodeID string) []models.TrashFolder {
+	var path []models.TrashFolder
+	for parent := FindParentOfNode(root, nodeID); parent != nil && parent != root; parent = FindParentOfNode(root, parent.ID) {
+		path = append([]models.TrashFolder{{ID: parent.ID, Name: parent.Name}}, path...)
+	}
+	return path
+}
+
+// RestoreFromTrash puts a deleted item back where it was and removes it from
+// the trash. Missing parent folders of a material are recreated, and a
+// folder that already exists again is merged with the restored one.
+// Purges wait for the restore to finish.
+func RestoreFromTrash(userID int64, itemID string) (models.TrashItem, error) {
+	restoreMutex.Lock()
+	defer restoreMutex.Unlock()
+
+	item, err := findTrashItem(userID, itemID)
+	if err != nil {
+		return models.TrashItem{}, err
+	}
+
+	switch item.Kind {
+	case models.TrashMaterial:
+		_, err = UpdateMaterials(userID, AnyRevision, func(tree *models.MaterialsTree) error {
+			return restoreNode(tree, item)
+		})
+	case models.TrashNote:
+		_, err = UpdateData(userID, AnyRevision, func(data *models.AppData) error {
+			for _, note := range data.Notes {
+				if note.ID == item.Note.ID {
+					return ErrRestoreConflict
+				}
+			}
+			data.Notes = append(data.Notes, *item.Note)
+			return nil
+		})
+	case models.TrashEvent:
+		_, err = UpdateData(userID, AnyRevision, func(data *models.AppData) error {
+			for _, event := range data.Events {
+				if event.ID == item.Event.ID {
+					return ErrRestoreConflict
+				}
+			}
+			data.Events = append(data.Events, *item.Event)
+			return nil
+		})
+	}
+	if err != nil {
+		return models.TrashItem{}, err
+	}
+
+	trashMutex.Lock()
+	defer trashMutex.Unlock()
+
+	data, err := loadTrash(userID)
+	if err != nil {
+		return models.TrashItem{}, err
+	}
+	data.Items = removeTrashItems(data.Items, func(other models.TrashItem) bool { return other.ID == itemID })
+	return item, saveDocument(userID, trashDocument, data)
+}
+
+// restoreNode adds a deleted material or folder back to its original folder
+func restoreNode(tree *models.MaterialsTree, item models.TrashItem) error {
+	parent := tree.Root
+	for _, folder := range item.Path {
+		existing := FindNodeByID(tree.Root, folder.ID)
+		if existing == nil {
+			existing = &models.MaterialNode{
+				ID:       folder.ID,
+				Name:     folder.Name,
+				Type:     "folder",
+				ParentID: parent.ID,
+				Children: []*models.MaterialNode{},
+			}
+			parent.Children = append(parent.Children, existing)
+		} else if existing.Type != "folder" {
+			return ErrRestoreConflict
+		}
+		parent = existing
+	}
+
+	if existing := FindNodeByID(tree.Root, item.Node.ID); existing != nil {
+		// The folder was recreated to restore a material deleted before it
+		if existing.Type != "folder" || item.Node.Type != "folder" {
+			return ErrRestoreConflict
+		}
+		mergeFolder(tree.Root, existing, item.Node)
+		return nil
+	}
+
+	item.Node.ParentID = parent.ID
+	parent.Children = append(parent.Children, item.Node)
+	return nil
+}
+
+// mergeFolder adds the children of src that are not in the tree to dst
+func mergeFolder(root, dst, src *models.MaterialNode) {
+	for _, child := range src.Children {
+		existing := FindNodeByID(root, child.ID)
+		if existing == nil {
+			child.ParentID = dst.ID
+			dst.Children = append(dst.Children, child)
+		} else if existing.Type == "folder" && child.Type == "folder" {
+			mergeFolder(root, existing, child)
+		}
+	}
+}
+
+// PurgeTrashItem permanently deletes one item of the trash
+func PurgeTrashItem(userID int64, itemID string) error {
+	purged, err := purgeTrash(userID, func(item models.TrashItem) bool { return item.ID == itemID })
+	if err == nil && purged == 0 {
+		return ErrTrashItemNotFound
+	}
+	return err
+}
+
+// EmptyTrash permanently deletes every item of the user's trash
+func EmptyTrash(userID int64) error {
+	_, err := purgeTrash(userID, func(models.TrashItem) bool { return true })
+	return err
+}
+
+// PurgeExpiredTrash permanently deletes the items that stayed in the trash
+// longer than the retention period
+func PurgeExpiredTrash() {
+	cutoff := time.Now().Add(-TrashRetention())
+	for _, user := range ListUsers() {
+		_, err := purgeTrash(user.ID, func(item models.TrashItem) bool {
+			deletedAt, err := time.Parse(time.RFC3339, item.DeletedAt)
+			return err == nil && deletedAt.Before(cutoff)
+		})
+		if err != nil {
+			log.Printf("ERROR: Could not purge expired trash of user %d: %v", user.ID, err)
+		}
+	}
+}
+
+// purgeTrash removes the items matching fn from the trash, then deletes the
+// history of purged notes and the uploaded files, extracted texts and
+// playback positions of purged materials. It returns the number of purged
+// items.
+func purgeTrash(userID int64, fn func(item models.TrashItem) bool) (int, error) {
+	restoreMutex.Lock()
+	defer restoreMutex.Unlock()
+
+	trashMutex.Lock()
+	data, err := loadTrash(userID)
+	if err != nil {
+		trashMutex.Unlock()
+		return 0, err
+	}
+	var purged []models.TrashItem
+	for _, item := range data.Items {
+		if fn(item) {
+			purged = append(purged, item)
+		}
+	}
+	if len(purged) > 0 {
+		data.Items = removeTrashItems(data.Items, fn)
+		err = saveDocument(userID, trashDocument, data)
+	}
+	// Released before ReleaseUploads, which takes the materials lock first
+	trashMutex.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	var files, ids []string
+	for _, item := range purged {
+		switch {
+		case item.Kind == models.TrashMaterial && item.Node != nil:
+			files = append(files, UploadPaths(item.Node)...)
+			ids = append(ids, nodeIDs(item.Node)...)
+		case item.Kind == models.TrashNote && item.Note != nil:
+			if err := forgetNoteHistory(userID, item.Note.ID); err != nil {
+				return len(purged), err
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return len(purged), nil
+	}
+	if err := ReleaseUploads(userID, files); err != nil {
+		return len(purged), err
+	}
+	if _, err := UpdateMaterialTexts(userID, func(texts *models.MaterialTextsData) error {
+		for _, id := range ids {
+			delete(texts.Texts, id)
+		}
+		return nil
+	}); err != nil {
+		return len(purged), err
+	}
+	_, err = UpdateMediaProgress(userID, func(progress *models.MediaProgressData) error {
+		for _, id := range ids {
+			delete(progress.Positions, id)
+		}
+		return nil
+	})
+	return len(purged), err
+}
+
+// RunTrashCollector calls PurgeExpiredTrash every interval until ctx is done
+func RunTrashCollector(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			PurgeExpiredTrash()
+		}
+	}
+}
+
+// addTrashedUploads adds the files referenced by the materials in the
+// user's trash to referenced, so they are kept until the trash is purged.
+// The caller holds materialsMutex.
+func addTrashedUploads(userID int64, referenced map[string]bool) error {
+	data, err := LoadTrash(userID)
+	if err != nil {
+		return err
+	}
+	for _, item := range data.Items {
+		for path := range referencedUploads(item.Node) {
+			referenced[path] = true
+		}
+	}
+	return nil
+}
+
+// TrashedNode returns the material or folder with the given ID from the
+// user's trash, or nil when no trashed item contains it
+func TrashedNode(userID int64, nodeID string) (*models.MaterialNode, error) {
+	data, err := LoadTrash(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range data.Items {
+		if node := FindNodeByID(item.Node, nodeID); node != nil {
+			return node, nil
+		}
+	}
+	return nil, nil
+}
+
+// findTrashItem returns an item of the user's trash by ID
+func findTrashItem(userID int64, itemID string) (models.TrashItem, error) {
+	data, err := LoadTrash(userID)
+	if err != nil {
+		return models.TrashItem{}, err
+	}
+	for _, item := range data.Items {
+		if item.ID == itemID {
+			return item, nil
+		}
+	}
+	return models.TrashItem{}, ErrTrashItemNotFound
+}
+
+// removeTrashItems returns items without the ones matching fn
+func removeTrashItems(items []models.TrashItem, fn func(item models.TrashItem) bool) []models.TrashItem {
+	kept := make([]models.TrashItem, 0, len(items))
+	for _, item := range items {
+		if !fn(item) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// nodeIDs lists the IDs of node and all its descendants
+func nodeIDs(node *models.MaterialNode) []string {
+	ids := []string{node.ID}
+	for _, child := range node.Children {
+		ids = append(ids, nodeIDs(child)...)
+	}
+	return ids
+}
+
+func loadTrash(userID int64) (models.TrashData, error) {
+	var data models.TrashData
+	if err := loadDocument(userID, trashDocument, &data); err != nil {
+		return models.TrashData{}, err
+	}
+	if data.Items == nil {
+		data.Items = []models.TrashItem{}
+	}
+	// Expiry follows the current retention setting
+	retention := TrashRetention()
+	for i, item := range data.Items {
+		if deletedAt, err := time.Parse(time.RFC3339, item.DeletedAt); err == nil {
+			data.Items[i].ExpiresAt = deletedAt.Add(retention).Format(time.RFC3339)
+		}
+	}
+	return data, nil
+}
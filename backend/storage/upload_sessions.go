@@ -0,0 +1,443 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"studybuddy/models"
+	"sync"
+	"time"
+)
+
+// uploadSessionsDocument is the name of the per-user document holding open
+// resumable uploads
+const uploadSessionsDocument = "upload_sessions"
+
+// partialDirName is the folder below a user's uploads directory holding the
+// bytes received by open upload sessions
+const partialDirName = "partial"
+
+// Resumable upload limits
+const (
+	// MaxResumableFileSize is the largest file accepted by a resumable upload
+	MaxResumableFileSize = 2 << 30 // 2 GB
+	// maxUploadSessions is how many uploads a user may have open at once
+	maxUploadSessions = 5
+	// uploadSessionTTL is how long an upload may go without new chunks
+	// before it is abandoned
+	uploadSessionTTL = 24 * time.Hour
+	// defaultUploadQuota is the storage available to each user
+	defaultUploadQuota = 5 << 30 // 5 GB
+)
+
+var (
+	// ErrUploadSessionNotFound is returned for unknown or expired upload sessions
+	ErrUploadSessionNotFound = errors.New("envio não encontrado ou expirado")
+	// ErrUploadOffsetMismatch is returned when a chunk does not start where
+	// the received bytes end
+	ErrUploadOffsetMismatch = errors.New("o deslocamento não corresponde aos bytes já recebidos")
+	// ErrUploadBusy is returned while another request writes to the session
+	ErrUploadBusy = errors.New("outra parte deste envio está sendo recebida")
+	// ErrUploadIncomplete is returned when finishing a session missing bytes
+	ErrUploadIncomplete = errors.New("o envio ainda não foi concluído")
+	// ErrTooManyUploads is returned when the user has too many open sessions
+	ErrTooManyUploads = errors.New("muitos envios em andamento; conclua ou cancele algum antes de começar outro")
+	// ErrUploadQuotaExceeded is returned when a file does not fit the user's quota
+	ErrUploadQuotaExceeded = errors.New("cota de armazenamento excedida")
+)
+
+var (
+	uploadSessionsMutex sync.Mutex
+	// busyUploads marks the sessions a request is writing to or finishing,
+	// keyed by user and session ID
+	busyUploads = make(map[string]bool)
+)
+
+// UploadQuota returns the bytes each user may store in uploads, set by
+// UPLOAD_QUOTA_MB (default 5 GB)
+func UploadQuota() int64 {
+	if value := os.Getenv("UPLOAD_QUOTA_MB"); value != "" {
+		if mb, err := strconv.ParseInt(value, 10, 64); err == nil && mb > 0 {
+			return mb << 20
+		}
+		log.Printf("WARNING: Invalid UPLOAD_QUOTA_MB %q, using default", value)
+	}
+	return defaultUploadQuota
+}
+
+// CheckUploadQuota returns ErrUploadQuotaExceeded when storing size more
+// bytes would exceed the user's quota. Open upload sessions count with
+// their declared size.
+func CheckUploadQuota(userID int64, size int64) error {
+	uploadSessionsMutex.Lock()
+	defer uploadSessionsMutex.Unlock()
+
+	data, err := loadUploadSessions(userID)
+	if err != nil {
+		return err
+	}
+	return checkUploadQuota(userID, data, size)
+}
+
+// CreateUploadSession starts a resumable upload of a file with the given
+// name and size. Abandoned sessions of the user are removed first.
+func CreateUploadSession(userID int64, fileName string, size int64) (models.UploadSession, error) {
+	uploadSessionsMutex.Lock()
+	defer uploadSessionsMutex.Unlock()
+
+	data, err := loadUploadSessions(userID)
+	if err != nil {
+		return models.UploadSession{}, err
+	}
+	expireUploadSessions(userID, &data, time.Now().Add(-uploadSessionTTL))
+
+	if len(data.Sessions) >= maxUploadSessions {
+		return models.UploadSession{}, ErrTooManyUploads
+	}
+	if err := checkUploadQuota(userID, data, size); err != nil {
+		return models.UploadSession{}, err
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	session := models.UploadSession{
+		ID:        generateID("upload"),
+		FileName:  fileName,
+		Size:      size,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := os.MkdirAll(partialDir(userID), 0755); err != nil {
+		return models.UploadSession{}, err
+	}
+	f, err := os.Create(partialPath(userID, session.ID))
+	if err != nil {
+		return models.UploadSession{}, err
+	}
+	f.Close()
+
+	data.Sessions = append(data.Sessions, session)
+	if err := saveDocument(userID, uploadSessionsDocument, data); err != nil {
+		os.Remove(partialPath(userID, session.ID))
+		return models.UploadSession{}, err
+	}
+	return session, nil
+}
+
+// GetUploadSession returns one of the user's open upload sessions
+func GetUploadSession(userID int64, id string) (models.UploadSession, error) {
+	uploadSessionsMutex.Lock()
+	defer uploadSessionsMutex.Unlock()
+
+	data, err := loadUploadSessions(userID)
+	if err != nil {
+		return models.UploadSession{}, err
+	}
+	index := findUploadSession(data, id)
+	if index == -1 {
+		return models.UploadSession{}, ErrUploadSessionNotFound
+	}
+	return data.Sessions[index], nil
+}
+
+// WriteUploadChunk appends the bytes of r to an upload session. offset must
+// equal the bytes already received. Bytes read before r fails, e.g. when
+// the connection drops, are kept so the client can resume from the
+// returned offset. The file is moved to the content-addressed store once
+// all bytes were received.
+func WriteUploadChunk(userID int64, id string, offset int64, r io.Reader) (models.UploadSession, error) {
+	session, err := claimUploadSession(userID, id)
+	if err != nil {
+		return session, err
+	}
+	defer releaseUploadSession(userID, id)
+
+	if session.FilePath != "" || offset != session.Offset {
+		return session, ErrUploadOffsetMismatch
+	}
+
+	partial := partialPath(userID, session.ID)
+	written, copyErr := appendChunk(partial, offset, io.LimitReader(r, session.Size-offset))
+
+	filePath := ""
+	if copyErr == nil && offset+written == session.Size {
+		filePath, copyErr = completeUpload(userID, partial)
+	}
+
+	uploadSessionsMutex.Lock()
+	defer uploadSessionsMutex.Unlock()
+
+	data, err := loadUploadSessions(userID)
+	if err != nil {
+		return session, err
+	}
+	index := findUploadSession(data, id)
+	if index == -1 {
+		return session, ErrUploadSessionNotFound
+	}
+	data.Sessions[index].Offset = offset + written
+	data.Sessions[index].FilePath = filePath
+	data.Sessions[index].UpdatedAt = time.Now().Format(time.RFC3339)
+	if err := saveDocument(userID, uploadSessionsDocument, data); err != nil {
+		return session, err
+	}
+	return data.Sessions[index], copyErr
+}
+
+// FinishUploadSession calls fn with a completed upload session and removes
+// the session when fn succeeds. fn typically creates the material that
+// references the session's file.
+func FinishUploadSession(userID int64, id string, fn func(session models.UploadSession) error) error {
+	session, err := claimUploadSession(userID, id)
+	if err != nil {
+		return err
+	}
+	defer releaseUploadSession(userID, id)
+
+	if session.FilePath == "" {
+		return ErrUploadIncomplete
+	}
+	if err := fn(session); err != nil {
+		return err
+	}
+
+	uploadSessionsMutex.Lock()
+	defer uploadSessionsMutex.Unlock()
+
+	data, err := loadUploadSessions(userID)
+	if err != nil {
+		return err
+	}
+	if index := findUploadSession(data, id); index != -1 {
+		data.Sessions = append(data.Sessions[:index], data.Sessions[index+1:]...)
+	}
+	return saveDocument(userID, uploadSessionsDocument, data)
+}
+
+// DeleteUploadSession cancels an upload and removes the received bytes
+func DeleteUploadSession(userID int64, id string) error {
+	uploadSessionsMutex.Lock()
+	defer uploadSessionsMutex.Unlock()
+
+	data, err := loadUploadSessions(userID)
+	if err != nil {
+		return err
+	}
+	index := findUploadSession(data, id)
+	if index == -1 {
+		return ErrUploadSessionNotFound
+	}
+	if busyUploads[uploadKey(userID, id)] {
+		return ErrUploadBusy
+	}
+
+	session := data.Sessions[index]
+	data.Sessions = append(data.Sessions[:index], data.Sessions[index+1:]...)
+	if err := saveDocument(userID, uploadSessionsDocument, data); err != nil {
+		return err
+	}
+	return removeUploadFiles(userID, session)
+}
+
+// expireAllUploadSessions removes the abandoned upload sessions of a user
+func expireAllUploadSessions(userID int64) error {
+	uploadSessionsMutex.Lock()
+	defer uploadSessionsMutex.Unlock()
+
+	data, err := loadUploadSessions(userID)
+	if err != nil {
+		return err
+	}
+	if expireUploadSessions(userID, &data, time.Now().Add(-uploadSessionTTL)) {
+		return saveDocument(userID, uploadSessionsDocument, data)
+	}
+	return nil
+}
+
+// expireUploadSessions drops the sessions without new chunks since before
+// and removes their files. It reports whether any session expired. The
+// caller holds uploadSessionsMutex.
+func expireUploadSessions(userID int64, data *models.UploadSessionsData, before time.Time) bool {
+	kept := data.Sessions[:0]
+	expired := false
+	for _, session := range data.Sessions {
+		updated, err := time.Parse(time.RFC3339, session.UpdatedAt)
+		if busyUploads[uploadKey(userID, session.ID)] || (err == nil && updated.After(before)) {
+			kept = append(kept, session)
+			continue
+		}
+		expired = true
+		if err := removeUploadFiles(userID, session); err != nil {
+			log.Printf("ERROR: Could not remove files of expired upload %s of user %d: %v", session.ID, userID, err)
+		}
+	}
+	data.Sessions = kept
+	return expired
+}
+
+// removeUploadFiles removes the received bytes of a session, or its stored
+// file when no material references it
+func removeUploadFiles(userID int64, session models.UploadSession) error {
+	if session.FilePath != "" {
+		return ReleaseUploads(userID, []string{session.FilePath})
+	}
+	if err := os.Remove(partialPath(userID, session.ID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// claimUploadSession marks a session as busy so concurrent requests cannot
+// write to, finish or cancel it
+func claimUploadSession(userID int64, id string) (models.UploadSession, error) {
+	uploadSessionsMutex.Lock()
+	defer uploadSessionsMutex.Unlock()
+
+	data, err := loadUploadSessions(userID)
+	if err != nil {
+		return models.UploadSession{}, err
+	}
+	index := findUploadSession(data, id)
+	if index == -1 {
+		return models.UploadSession{}, ErrUploadSessionNotFound
+	}
+	key := uploadKey(userID, id)
+	if busyUploads[key] {
+		return data.Sessions[index], ErrUploadBusy
+	}
+	busyUploads[key] = true
+	return data.Sessions[index], nil
+}
+
+// releaseUploadSession clears the mark set by claimUploadSession
+func releaseUploadSession(userID int64, id string) {
+	uploadSessionsMutex.Lock()
+	defer uploadSessionsMutex.Unlock()
+	delete(busyUploads, uploadKey(userID, id))
+}
+
+// appendChunk writes r to the partial file at offset and returns the number
+// of bytes written, even when r fails
+func appendChunk(path string, offset int64, r io.Reader) (int64, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return 0, err
+	}
+	written, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return written, err
+}
+
+// completeUpload moves a fully received partial file to the
+// content-addressed store and returns its path
+func completeUpload(userID int64, partial string) (string, error) {
+	f, err := os.Open(partial)
+	if err != nil {
+		return "", err
+	}
+	sum, err := hashFile(f)
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+
+	materialsMutex.Lock()
+	defer materialsMutex.Unlock()
+
+	if err := os.MkdirAll(userBlobsDir(userID), 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(userBlobsDir(userID), sum)
+	if err := commitBlob(partial, path); err != nil {
+		return "", err
+	}
+	// commitBlob keeps the partial file when the blob already existed
+	os.Remove(partial)
+	return path, nil
+}
+
+// checkUploadQuota checks size more bytes against the user's quota. The
+// caller holds uploadSessionsMutex.
+func checkUploadQuota(userID int64, data models.UploadSessionsData, size int64) error {
+	used, err := uploadsUsage(userID)
+	if err != nil {
+		return err
+	}
+	for _, session := range data.Sessions {
+		if session.FilePath == "" {
+			used += session.Size
+		}
+	}
+	if used+size > UploadQuota() {
+		return ErrUploadQuotaExceeded
+	}
+	return nil
+}
+
+// uploadsUsage returns the bytes stored in the user's uploads, excluding
+// the partial files of open sessions
+func uploadsUsage(userID int64) (int64, error) {
+	var used int64
+	err := filepath.WalkDir(UserUploadsDir(userID), func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path == partialDir(userID) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		used += info.Size()
+		return nil
+	})
+	return used, err
+}
+
+func loadUploadSessions(userID int64) (models.UploadSessionsData, error) {
+	var data models.UploadSessionsData
+	err := loadDocument(userID, uploadSessionsDocument, &data)
+	return data, err
+}
+
+// findUploadSession returns the index of a session, or -1
+func findUploadSession(data models.UploadSessionsData, id string) int {
+	for i, session := range data.Sessions {
+		if session.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// partialDir returns the directory holding the partial files of a user's sessions
+func partialDir(userID int64) string {
+	return filepath.Join(UserUploadsDir(userID), partialDirName)
+}
+
+// partialPath returns the file holding the bytes received by a session
+func partialPath(userID int64, id string) string {
+	return filepath.Join(partialDir(userID), id)
+}
+
+// uploadKey identifies a session in busyUploads
+func uploadKey(userID int64, id string) string {
+	return fmt.Sprintf("%d/%s", userID, id)
+}
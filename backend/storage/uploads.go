@@ -0,0 +1,398 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"studybuddy/models"
+	"time"
+)
+
+// blobsDirName is the folder below a user's uploads directory holding the
+// uploaded files, each named by the SHA-256 of its content
+const blobsDirName = "blobs"
+
+// orphanUploadAge is how long an upload may stay unreferenced, e.g. while
+// the client creates its material, before it is collected
+const orphanUploadAge = 24 * time.Hour
+
+// staleTempAge is how old a temporary file must be before the sweep treats
+// it as left behind by a crash rather than as a write in progress
+const staleTempAge = 7 * 24 * time.Hour
+
+// ErrUploadNotFound is returned when a material references a file that is
+// not one of the user's uploads
+var ErrUploadNotFound = errors.New("arquivo enviado não encontrado, envie o arquivo novamente")
+
+// thumbnailsRoot holds the cached thumbnails of each user's uploads
+var thumbnailsRoot = "storage/thumbnails"
+
+// ThumbnailPath returns where the thumbnail of an uploaded file is cached.
+// Thumbnails are named after the blob, so identical files share one.
+func ThumbnailPath(userID int64, filePath string) string {
+	return filepath.Join(userThumbnailsDir(userID), filepath.Base(filePath)+".jpg")
+}
+
+// userThumbnailsDir returns the directory holding a user's cached thumbnails
+func userThumbnailsDir(userID int64) string {
+	return filepath.Join(thumbnailsRoot, fmt.Sprintf("%d", userID))
+}
+
+// removeUpload removes an uploaded file and its cached thumbnail
+func removeUpload(userID int64, path string) error {
+	if err := os.Remove(ThumbnailPath(userID, path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// userBlobsDir returns the directory holding a user's content-addressed uploads
+func userBlobsDir(userID int64) string {
+	return filepath.Join(UserUploadsDir(userID), blobsDirName)
+}
+
+// StoreUpload saves an uploaded file under the SHA-256 of its content and
+// returns its path and size. Uploading the same content again returns the
+// existing file instead of storing a copy.
+func StoreUpload(userID int64, r io.Reader) (string, int64, error) {
+	tmp, sum, size, err := writeTemp(userBlobsDir(userID), r)
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp)
+
+	// Hold the materials lock so a concurrent ReleaseUploads cannot remove
+	// the blob between the check and the rename
+	materialsMutex.Lock()
+	defer materialsMutex.Unlock()
+
+	path := filepath.Join(userBlobsDir(userID), sum)
+	return path, size, commitBlob(tmp, path)
+}
+
+// hashFile returns the hex SHA-256 of the content of r
+func hashFile(r io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeTemp copies r to a temporary file in dir and returns its name, the
+// hex SHA-256 of the content and its size
+func writeTemp(dir string, r io.Reader) (string, string, int64, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", 0, err
+	}
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", "", 0, err
+	}
+	return tmp.Name(), hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// commitBlob moves a temporary file to its blob path. When the blob already
+// exists its modification time is refreshed instead, so the orphan sweep
+// keeps it until the new material references it. The caller holds
+// materialsMutex.
+func commitBlob(tmp, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		now := time.Now()
+		return os.Chtimes(path, now, now)
+	}
+	return os.Rename(tmp, path)
+}
+
+// CheckUpload returns the cleaned path of a file referenced by a new
+// material, or ErrUploadNotFound when it is not an existing upload of the
+// user. It is meant to run inside UpdateMaterials so the file cannot be
+// collected before the material is saved.
+func CheckUpload(userID int64, path string) (string, error) {
+	cleanPath := filepath.Clean(path)
+	if filepath.Dir(cleanPath) != userBlobsDir(userID) {
+		return "", ErrUploadNotFound
+	}
+	if info, err := os.Stat(cleanPath); err != nil || !info.Mode().IsRegular() {
+		return "", ErrUploadNotFound
+	}
+	return cleanPath, nil
+}
+
+// AdoptUpload copies one of a user's checked uploads into the owner's
+// store and returns its path there, so a material an editor adds to a
+// shared folder keeps its file when the editor's upload is collected. It
+// is meant to run inside the owner's UpdateMaterials.
+func AdoptUpload(ownerID int64, path string) (string, error) {
+	return copyToBlob(ownerID, path)
+}
+
+// ReleaseUploads removes the given files of purged materials once no
+// remaining material of the user, in the tree or in the trash, references
+// them
+func ReleaseUploads(userID int64, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	materialsMutex.Lock()
+	defer materialsMutex.Unlock()
+
+	tree, err := backend.LoadMaterials(userID)
+	if errors.Is(err, ErrNotFound) {
+		tree = createDefaultTree()
+	} else if err != nil {
+		return err
+	}
+
+	referenced := referencedUploads(tree.Root)
+	if err := addTrashedUploads(userID, referenced); err != nil {
+		return err
+	}
+	for _, path := range paths {
+		cleanPath := filepath.Clean(path)
+		if referenced[cleanPath] || !isUserUpload(userID, cleanPath) {
+			continue
+		}
+		if err := removeUpload(userID, cleanPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UploadPaths lists the file paths referenced by node and its descendants,
+// including previous file versions
+func UploadPaths(node *models.MaterialNode) []string {
+	var paths []string
+	for path := range referencedUploads(node) {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// CollectUploads moves every user's uploads stored under their original
+// name into the content-addressed store, removes abandoned upload sessions
+// and removes uploads that no material references any more
+func CollectUploads() {
+	for _, user := range ListUsers() {
+		if err := consolidateUploads(user.ID); err != nil {
+			log.Printf("ERROR: Could not consolidate uploads of user %d: %v", user.ID, err)
+			continue
+		}
+		if err := expireAllUploadSessions(user.ID); err != nil {
+			log.Printf("ERROR: Could not expire upload sessions of user %d: %v", user.ID, err)
+			continue
+		}
+		if err := sweepUploads(user.ID, time.Now().Add(-orphanUploadAge)); err != nil {
+			log.Printf("ERROR: Could not remove orphaned uploads of user %d: %v", user.ID, err)
+		}
+	}
+}
+
+// consolidateUploads replaces files uploaded before the content-addressed
+// store by their blob, merging duplicates. The original files are removed
+// once the updated tree is saved.
+func consolidateUploads(userID int64) error {
+	tree, err := LoadMaterials(userID)
+	if err != nil {
+		return err
+	}
+	if !hasLegacyUploads(userID, tree.Root) {
+		return nil
+	}
+
+	moved := make(map[string]string)
+	_, err = UpdateMaterials(userID, AnyRevision, func(tree *models.MaterialsTree) error {
+		var walk func(node *models.MaterialNode) error
+		walk = func(node *models.MaterialNode) error {
+			if isLegacyUpload(userID, node) {
+				oldPath := filepath.Clean(node.FilePath)
+				newPath, ok := moved[oldPath]
+				if !ok {
+					var err error
+					if newPath, err = copyToBlob(userID, oldPath); err != nil {
+						return err
+					}
+					moved[oldPath] = newPath
+				}
+				node.FilePath = newPath
+			}
+			for _, child := range node.Children {
+				if err := walk(child); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return walk(tree.Root)
+	})
+	if err != nil {
+		return err
+	}
+
+	for oldPath := range moved {
+		if err := os.Remove(oldPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	log.Printf("INFO: Moved %d uploads of user %d to the content-addressed store", len(moved), userID)
+	return nil
+}
+
+// copyToBlob copies a file into the content-addressed store and returns
+// its blob path. The caller holds materialsMutex.
+func copyToBlob(userID int64, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	tmp, sum, _, err := writeTemp(userBlobsDir(userID), f)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp)
+
+	blob := filepath.Join(userBlobsDir(userID), sum)
+	return blob, commitBlob(tmp, blob)
+}
+
+// sweepUploads removes the user's uploads that no material references and
+// that were not modified since before. Partial files of resumable uploads
+// are removed when their session expires, and temporary files only once
+// they are older than staleTempAge.
+func sweepUploads(userID int64, before time.Time) error {
+	materialsMutex.Lock()
+	defer materialsMutex.Unlock()
+
+	tree, err := backend.LoadMaterials(userID)
+	if errors.Is(err, ErrNotFound) {
+		tree = createDefaultTree()
+	} else if err != nil {
+		return err
+	}
+	referenced := referencedUploads(tree.Root)
+	if err := addTrashedUploads(userID, referenced); err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(UserUploadsDir(userID), func(path string, entry os.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path == partialDir(userID) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if referenced[path] {
+			return nil
+		}
+		cutoff := before
+		if strings.HasPrefix(entry.Name(), ".upload-") {
+			cutoff = time.Now().Add(-staleTempAge)
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return err
+		}
+		log.Printf("INFO: Removing orphaned upload %s", path)
+		return removeUpload(userID, path)
+	})
+}
+
+// referencedUploads returns the set of file paths referenced by node and
+// its descendants, including previous file versions
+func referencedUploads(node *models.MaterialNode) map[string]bool {
+	referenced := make(map[string]bool)
+	var walk func(node *models.MaterialNode)
+	walk = func(node *models.MaterialNode) {
+		if node == nil {
+			return
+		}
+		if node.IsFile && node.FilePath != "" {
+			referenced[filepath.Clean(node.FilePath)] = true
+		}
+		for _, version := range node.Versions {
+			referenced[filepath.Clean(version.FilePath)] = true
+		}
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(node)
+	return referenced
+}
+
+// hasLegacyUploads reports whether node or a descendant references a file
+// stored outside the content-addressed store
+func hasLegacyUploads(userID int64, node *models.MaterialNode) bool {
+	if node == nil {
+		return false
+	}
+	if isLegacyUpload(userID, node) {
+		return true
+	}
+	for _, child := range node.Children {
+		if hasLegacyUploads(userID, child) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLegacyUpload reports whether node references one of the user's uploads
+// stored under its original name
+func isLegacyUpload(userID int64, node *models.MaterialNode) bool {
+	if !node.IsFile || node.FilePath == "" {
+		return false
+	}
+	path := filepath.Clean(node.FilePath)
+	return isUserUpload(userID, path) && filepath.Dir(path) != userBlobsDir(userID)
+}
+
+// isUserUpload reports whether a cleaned path lies inside the user's uploads directory
+func isUserUpload(userID int64, path string) bool {
+	return strings.HasPrefix(path, UserUploadsDir(userID)+string(filepath.Separator))
+}
+
+// RunUploadCollector calls CollectUploads every interval until ctx is done
+func RunUploadCollector(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			CollectUploads()
+		}
+	}
+}
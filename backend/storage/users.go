@@ -1,7 +1,7 @@
 package storage
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -14,12 +14,14 @@ import (
 )
 
 var (
-	usersFile  = "storage/users.json"
 	users      = make(map[string]models.User)
 	usersMutex sync.Mutex
 	jwtSecret  []byte
 )
 
+// ErrEmailTaken is returned when an email is already used by another account
+var ErrEmailTaken = errors.New("email já cadastrado")
+
 func init() {
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
@@ -34,31 +36,31 @@ func GetJWTSecret() []byte {
 	return jwtSecret
 }
 
-// LoadUsers loads users from the JSON file
+// LoadUsers loads users from the storage backend
 func LoadUsers() {
 	usersMutex.Lock()
 	defer usersMutex.Unlock()
 
-	bytes, err := os.ReadFile(usersFile)
+	loaded, err := backend.LoadUsers()
 	if err != nil {
-		log.Printf("INFO: Could not read users file: %v (this is normal on first run)", err)
+		log.Printf("WARNING: Could not load users: %v", err)
 		return
 	}
-	if err := json.Unmarshal(bytes, &users); err != nil {
-		log.Printf("WARNING: Could not parse users file: %v", err)
-	}
+	users = loaded
 }
 
-// SaveUsers saves users to the JSON file
-func SaveUsers() {
-	bytes, err := json.MarshalIndent(users, "", "  ")
-	if err != nil {
-		log.Printf("ERROR: Could not serialize users: %v", err)
-		return
+// saveUser persists a user and updates the in-memory cache.
+// Must be called with usersMutex held.
+func saveUser(previousEmail string, user models.User) error {
+	if err := backend.SaveUser(user); err != nil {
+		log.Printf("ERROR: Could not save user %s: %v", user.Email, err)
+		return err
 	}
-	if err := os.WriteFile(usersFile, bytes, 0644); err != nil {
-		log.Printf("ERROR: Could not write users file: %v", err)
+	if previousEmail != "" && previousEmail != user.Email {
+		delete(users, previousEmail)
 	}
+	users[user.Email] = user
+	return nil
 }
 
 // GetUser retrieves a user by email
@@ -69,6 +71,26 @@ func GetUser(email string) (models.User, bool) {
 	return user, exists
 }
 
+// GetUserByID retrieves a user by ID
+func GetUserByID(id int64) (models.User, bool) {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+	_, user, exists := findUserByID(id)
+	return user, exists
+}
+
+// ListUsers returns all users
+func ListUsers() []models.User {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	list := make([]models.User, 0, len(users))
+	for _, user := range users {
+		list = append(list, user)
+	}
+	return list
+}
+
 // UserExists checks if a user exists by email
 func UserExists(email string) bool {
 	usersMutex.Lock()
@@ -88,18 +110,129 @@ func CreateUser(email, password, name string) (models.User, error) {
 	}
 
 	user := models.User{
-		ID:        time.Now().Unix(),
+		ID:        nextUserID(),
 		Email:     email,
 		Password:  hashedPassword,
 		Name:      name,
 		CreatedAt: time.Now().Format(time.RFC3339),
 	}
 
-	users[email] = user
-	SaveUsers()
+	if err := saveUser("", user); err != nil {
+		return models.User{}, err
+	}
 	return user, nil
 }
 
+// UpdatePassword hashes and stores a new password for a user
+func UpdatePassword(id int64, newPassword string) (models.User, error) {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	email, user, exists := findUserByID(id)
+	if !exists {
+		return models.User{}, errors.New("usuário não encontrado")
+	}
+
+	hashedPassword, err := HashPassword(newPassword)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user.Password = hashedPassword
+	if err := saveUser(email, user); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// findUserByID returns the email key and user with the given ID.
+// Must be called with usersMutex held.
+func findUserByID(id int64) (string, models.User, bool) {
+	for email, user := range users {
+		if user.ID == id {
+			return email, user, true
+		}
+	}
+	return "", models.User{}, false
+}
+
+// UpdateUserName changes the display name of a user
+func UpdateUserName(id int64, name string) (models.User, error) {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	email, user, exists := findUserByID(id)
+	if !exists {
+		return models.User{}, errors.New("usuário não encontrado")
+	}
+
+	user.Name = name
+	if err := saveUser(email, user); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// ChangeUserEmail changes the email of a user, re-keying the users map
+func ChangeUserEmail(id int64, newEmail string) (models.User, error) {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	oldEmail, user, exists := findUserByID(id)
+	if !exists {
+		return models.User{}, errors.New("usuário não encontrado")
+	}
+	if oldEmail == newEmail {
+		return user, nil
+	}
+	if _, taken := users[newEmail]; taken {
+		return models.User{}, ErrEmailTaken
+	}
+
+	user.Email = newEmail
+	if err := saveUser(oldEmail, user); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// DeleteUser removes a user account. The user's data must be removed
+// separately with DeleteUserData.
+func DeleteUser(id int64) error {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	email, _, exists := findUserByID(id)
+	if !exists {
+		return errors.New("usuário não encontrado")
+	}
+
+	if err := backend.DeleteUser(id); err != nil {
+		return err
+	}
+	delete(users, email)
+	return nil
+}
+
+// nextUserID returns an unused user ID based on the current time.
+// Must be called with usersMutex held.
+func nextUserID() int64 {
+	id := time.Now().Unix()
+	for {
+		taken := false
+		for _, user := range users {
+			if user.ID == id {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			return id
+		}
+		id++
+	}
+}
+
 // HashPassword hashes a password using bcrypt
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
@@ -111,16 +244,12 @@ func CheckPasswordHash(password, hash string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
 }
 
-// GenerateJWT generates a JWT token for a user
-func GenerateJWT(user models.User, rememberMe bool) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
-	if rememberMe {
-		expirationTime = time.Now().Add(7 * 24 * time.Hour)
-	}
-
+// GenerateJWT generates a short-lived access token for a user session
+func GenerateJWT(user models.User, sessionID string) (string, error) {
 	claims := &jwt.RegisteredClaims{
+		ID:        sessionID,
 		Subject:   fmt.Sprintf("%d", user.ID),
-		ExpiresAt: jwt.NewNumericDate(expirationTime),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 		IssuedAt:  jwt.NewNumericDate(time.Now()),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+)
+
+// VAPIDKeys represents the P-256 key pair identifying this server to Web
+// Push services, encoded as unpadded base64url (raw private scalar and
+// uncompressed public point)
+type VAPIDKeys struct {
+	PublicKey  string `json:"publicKey"`
+	PrivateKey string `json:"privateKey"`
+}
+
+// vapidKeysDocument is the name of the document holding generated VAPID keys
+const vapidKeysDocument = "vapid_keys"
+
+var vapidMutex sync.Mutex
+
+// LoadVAPIDKeys returns the keys from VAPID_PUBLIC_KEY and VAPID_PRIVATE_KEY.
+// Without them a key pair is generated once and kept in the storage backend,
+// so browser subscriptions stay valid across restarts.
+func LoadVAPIDKeys() (VAPIDKeys, error) {
+	public, private := os.Getenv("VAPID_PUBLIC_KEY"), os.Getenv("VAPID_PRIVATE_KEY")
+	if public != "" || private != "" {
+		if public == "" || private == "" {
+			return VAPIDKeys{}, errors.New("VAPID_PUBLIC_KEY and VAPID_PRIVATE_KEY must be set together")
+		}
+		return VAPIDKeys{PublicKey: public, PrivateKey: private}, nil
+	}
+
+	vapidMutex.Lock()
+	defer vapidMutex.Unlock()
+
+	var keys VAPIDKeys
+	bytes, err := backend.ReadDocument(systemScope, vapidKeysDocument)
+	if err == nil {
+		if err := json.Unmarshal(bytes, &keys); err != nil {
+			return VAPIDKeys{}, err
+		}
+		return keys, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return VAPIDKeys{}, err
+	}
+
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return VAPIDKeys{}, err
+	}
+	keys = VAPIDKeys{
+		PublicKey:  base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		PrivateKey: base64.RawURLEncoding.EncodeToString(key.Bytes()),
+	}
+
+	bytes, err = json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return VAPIDKeys{}, err
+	}
+	if err := backend.WriteDocument(systemScope, vapidKeysDocument, bytes); err != nil {
+		return VAPIDKeys{}, err
+	}
+	return keys, nil
+}